@@ -4,9 +4,8 @@ package tamtam
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -23,15 +22,18 @@ type Api struct {
 	Subscriptions *subscriptions
 	Uploads       *uploads
 	client        *client
-	timeout       int
-	pause         int
+	timeout       time.Duration
+	pause         time.Duration
+	markers       MarkerStore
+	decoders      decoders
+	journal       *Journal
+	sessions      SessionStore
 }
 
 // New TamTam Api object
-func New(key string) *Api {
-	timeout := 30
-	u, _ := url.Parse("https://botapi.tamtam.chat/")
-	cl := newClient(key, "0.1.8", u, &http.Client{Timeout: time.Duration(timeout) * time.Second})
+func New(key string, opts ...Option) *Api {
+	o := newOptions(opts)
+	cl := newClient(key, o)
 	return &Api{
 		Bots:          newBots(cl),
 		Chats:         newChats(cl),
@@ -39,118 +41,22 @@ func New(key string) *Api {
 		Messages:      newMessages(cl),
 		Subscriptions: newSubscriptions(cl),
 		client:        cl,
-		timeout:       timeout,
-		pause:         1,
+		timeout:       o.timeout,
+		pause:         o.pause,
+		markers:       o.markerStore,
+		decoders:      o.decoders,
+		journal:       o.journal,
+		sessions:      o.sessionStore,
 	}
 }
 
-func (a *Api) bytesToProperUpdate(b []byte) schemes.UpdateInterface {
-	u := new(schemes.Update)
-	_ = json.Unmarshal(b, u)
-	switch u.GetUpdateType() {
-	case schemes.TypeMessageCallback:
-		upd := new(schemes.MessageCallbackUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeMessageCreated:
-		upd := new(schemes.MessageCreatedUpdate)
-		_ = json.Unmarshal(b, upd)
-		for _, att := range upd.Message.Body.RawAttachments {
-			upd.Message.Body.Attachments = append(upd.Message.Body.Attachments, a.bytesToProperAttachment(att))
-		}
-		return upd
-	case schemes.TypeMessageRemoved:
-		upd := new(schemes.MessageRemovedUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeMessageEdited:
-		upd := new(schemes.MessageEditedUpdate)
-		_ = json.Unmarshal(b, upd)
-		for _, att := range upd.Message.Body.RawAttachments {
-			upd.Message.Body.Attachments = append(upd.Message.Body.Attachments, a.bytesToProperAttachment(att))
-		}
-		return upd
-	case schemes.TypeBotAdded:
-		upd := new(schemes.BotAddedToChatUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeBotRemoved:
-		upd := new(schemes.BotRemovedFromChatUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeUserAdded:
-		upd := new(schemes.UserAddedToChatUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeUserRemoved:
-		upd := new(schemes.UserRemovedFromChatUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeBotStarted:
-		upd := new(schemes.BotStartedUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	case schemes.TypeChatTitleChanged:
-		upd := new(schemes.ChatTitleChangedUpdate)
-		_ = json.Unmarshal(b, upd)
-		return upd
-	}
-	return nil
-}
-
-func (a *Api) bytesToProperAttachment(b []byte) schemes.AttachmentInterface {
-	attachment := new(schemes.Attachment)
-	_ = json.Unmarshal(b, attachment)
-	switch attachment.GetAttachmentType() {
-	case schemes.AttachmentAudio:
-		res := new(schemes.AudioAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentContact:
-		res := new(schemes.ContactAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentFile:
-		res := new(schemes.FileAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentImage:
-		res := new(schemes.PhotoAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentKeyboard:
-		res := new(schemes.InlineKeyboardAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentLocation:
-		res := new(schemes.LocationAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentShare:
-		res := new(schemes.ShareAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentSticker:
-		res := new(schemes.StickerAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	case schemes.AttachmentVideo:
-		res := new(schemes.VideoAttachment)
-		_ = json.Unmarshal(b, res)
-		return res
-	}
-	return attachment
-}
-
-func (a *Api) getUpdates(limit int, timeout int, marker int64, types []string) (*schemes.UpdateList, error) {
+func (a *Api) getUpdates(ctx context.Context, limit int, timeout time.Duration, marker int64, types []string) (*schemes.UpdateList, error) {
 	result := new(schemes.UpdateList)
 	values := url.Values{}
 	if limit > 0 {
 		values.Set("limit", strconv.Itoa(limit))
 	}
-	if timeout > 0 {
-		values.Set("timeout", strconv.Itoa(timeout))
-	}
+	values.Set("timeout", strconv.Itoa(int(timeout/time.Second)))
 	if marker > 0 {
 		values.Set("marker", strconv.Itoa(int(marker)))
 	}
@@ -159,62 +65,55 @@ func (a *Api) getUpdates(limit int, timeout int, marker int64, types []string) (
 			values.Add("types", t)
 		}
 	}
-	body, err := a.client.request(http.MethodGet, "updates", values, nil)
-	if err != nil {
-		if err == errLongPollTimeout {
-			return result, nil
-		}
-		return result, err
+	pollCtx, cancel := withLongPoll(ctx, timeout)
+	defer cancel()
+	err := a.client.call(pollCtx, "GetUpdates", http.MethodGet, "updates", values, nil, result)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		// Server has not answered within poll timeout
+		return result, &TimeoutError{Method: http.MethodGet, Path: "/updates", Err: err}
 	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	jb, _ := ioutil.ReadAll(body)
-	return result, json.Unmarshal(jb, result)
+	return result, err
 }
 
 //GetUpdates returns updates channel
 func (a *Api) GetUpdates(ctx context.Context) chan schemes.UpdateInterface {
-	ch := make(chan schemes.UpdateInterface)
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				close(ch)
-				return
-			case <-time.After(time.Duration(a.pause) * time.Second):
-				var marker int64
-				for {
-					upds, err := a.getUpdates(50, a.timeout, marker, []string{})
-					if err != nil {
-						log.Println(err)
-						break
-					}
-					if len(upds.Updates) == 0 {
-						break
-					}
-					for _, u := range upds.Updates {
-						ch <- a.bytesToProperUpdate(u)
-					}
-					marker = *upds.Marker
-				}
-			}
-		}
-	}()
-	return ch
+	return a.GetUpdatesWithConfig(ctx, PollerConfig{})
 }
 
 //GetHandler returns http handler for webhooks
+//
+//Deprecated: use NewWebhook, it validates requests, answers with proper status codes and delivers typed updates
 func (a *Api) GetHandler(updates chan interface{}) http.HandlerFunc {
+	return a.GetHandlerWithContext(context.Background(), updates)
+}
+
+//GetHandlerWithContext returns http handler for webhooks. Handler stops waiting for reader of updates channel when ctx is done or webhook request is cancelled and answers 503, so server can redeliver update later
+//
+//Deprecated: use NewWebhook
+func (a *Api) GetHandlerWithContext(ctx context.Context, updates chan interface{}) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := r.Body.Close(); err != nil {
-				log.Println(err)
+				a.client.logger.Warn("close request body", "err", err)
 			}
 		}()
-		b, _ := ioutil.ReadAll(r.Body)
-		updates <- a.bytesToProperUpdate(b)
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			a.client.logger.Warn("read webhook body", "err", err)
+		}
+		upd, err := a.DecodeUpdate(b)
+		if err != nil {
+			a.client.logger.Warn("decode webhook update", "err", err)
+		}
+		if upd != nil {
+			a.client.logger.Debug("webhook update received", "update_type", upd.GetUpdateType(), "chat_id", upd.GetChatID(), "user_id", upd.GetUserID())
+		}
+		select {
+		case updates <- upd:
+		case <-ctx.Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
 	}
 }
@@ -1,8 +1,7 @@
 package tamtam
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"net/http"
 	"net/url"
 
@@ -19,32 +18,24 @@ func newBots(client *client) *bots {
 
 //GetBot returns info about current bot. Current bot can be identified by access token. Method returns bot identifier, name and avatar (if any)
 func (a *bots) GetBot() (*schemes.BotInfo, error) {
+	return a.GetBotWithContext(context.Background())
+}
+
+//GetBotWithContext is GetBot bound to ctx
+func (a *bots) GetBotWithContext(ctx context.Context) (*schemes.BotInfo, error) {
 	result := new(schemes.BotInfo)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodGet, "me", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Bots.GetBot", http.MethodGet, "me", values, nil, result)
 }
 
 //PatchBot edits current bot info. Fill only the fields you want to update. All remaining fields will stay untouched
 func (a *bots) PatchBot(patch *schemes.BotPatch) (*schemes.BotInfo, error) {
+	return a.PatchBotWithContext(context.Background(), patch)
+}
+
+//PatchBotWithContext is PatchBot bound to ctx
+func (a *bots) PatchBotWithContext(ctx context.Context, patch *schemes.BotPatch) (*schemes.BotInfo, error) {
 	result := new(schemes.BotInfo)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodPatch, "me", values, patch)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Bots.PatchBot", http.MethodPatch, "me", values, patch, result)
 }
@@ -1,9 +1,8 @@
 package tamtam
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -21,6 +20,11 @@ func newChats(client *client) *chats {
 
 //GetChats returns information about chats that bot participated in: a result list and marker points to the next page
 func (a *chats) GetChats(count, marker int64) (*schemes.ChatList, error) {
+	return a.GetChatsWithContext(context.Background(), count, marker)
+}
+
+//GetChatsWithContext is GetChats bound to ctx
+func (a *chats) GetChatsWithContext(ctx context.Context, count, marker int64) (*schemes.ChatList, error) {
 	result := new(schemes.ChatList)
 	values := url.Values{}
 	if count > 0 {
@@ -29,52 +33,40 @@ func (a *chats) GetChats(count, marker int64) (*schemes.ChatList, error) {
 	if marker > 0 {
 		values.Set("marker", strconv.Itoa(int(marker)))
 	}
-	body, err := a.client.request(http.MethodGet, "chats", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.GetChats", http.MethodGet, "chats", values, nil, result)
 }
 
 //GetChat returns info about chat
 func (a *chats) GetChat(chatID int64) (*schemes.Chat, error) {
+	return a.GetChatWithContext(context.Background(), chatID)
+}
+
+//GetChatWithContext is GetChat bound to ctx
+func (a *chats) GetChatWithContext(ctx context.Context, chatID int64) (*schemes.Chat, error) {
 	result := new(schemes.Chat)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodGet, fmt.Sprintf("chats/%d", chatID), values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.GetChat", http.MethodGet, fmt.Sprintf("chats/%d", chatID), values, nil, result)
 }
 
 //GetChatMembership returns chat membership info for current bot
 func (a *chats) GetChatMembership(chatID int64) (*schemes.ChatMember, error) {
+	return a.GetChatMembershipWithContext(context.Background(), chatID)
+}
+
+//GetChatMembershipWithContext is GetChatMembership bound to ctx
+func (a *chats) GetChatMembershipWithContext(ctx context.Context, chatID int64) (*schemes.ChatMember, error) {
 	result := new(schemes.ChatMember)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodGet, fmt.Sprintf("chats/%d/members/me", chatID), values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.GetChatMembership", http.MethodGet, fmt.Sprintf("chats/%d/members/me", chatID), values, nil, result)
 }
 
 //GetChatMembers returns users participated in chat
 func (a *chats) GetChatMembers(chatID, count, marker int64) (*schemes.ChatMembersList, error) {
+	return a.GetChatMembersWithContext(context.Background(), chatID, count, marker)
+}
+
+//GetChatMembersWithContext is GetChatMembers bound to ctx
+func (a *chats) GetChatMembersWithContext(ctx context.Context, chatID, count, marker int64) (*schemes.ChatMembersList, error) {
 	result := new(schemes.ChatMembersList)
 	values := url.Values{}
 	if count > 0 {
@@ -83,95 +75,66 @@ func (a *chats) GetChatMembers(chatID, count, marker int64) (*schemes.ChatMember
 	if marker > 0 {
 		values.Set("marker", strconv.Itoa(int(marker)))
 	}
-	body, err := a.client.request(http.MethodGet, fmt.Sprintf("chats/%d/members", chatID), values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.GetChatMembers", http.MethodGet, fmt.Sprintf("chats/%d/members", chatID), values, nil, result)
 }
 
 //LeaveChat removes bot from chat members
 func (a *chats) LeaveChat(chatID int64) (*schemes.SimpleQueryResult, error) {
+	return a.LeaveChatWithContext(context.Background(), chatID)
+}
+
+//LeaveChatWithContext is LeaveChat bound to ctx
+func (a *chats) LeaveChatWithContext(ctx context.Context, chatID int64) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodDelete, fmt.Sprintf("chats/%d/members/me", chatID), values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.LeaveChat", http.MethodDelete, fmt.Sprintf("chats/%d/members/me", chatID), values, nil, result)
 }
 
 //EditChat edits chat info: title, icon, etc…
 func (a *chats) EditChat(chatID int64, update *schemes.ChatPatch) (*schemes.Chat, error) {
+	return a.EditChatWithContext(context.Background(), chatID, update)
+}
+
+//EditChatWithContext is EditChat bound to ctx
+func (a *chats) EditChatWithContext(ctx context.Context, chatID int64, update *schemes.ChatPatch) (*schemes.Chat, error) {
 	result := new(schemes.Chat)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodPatch, fmt.Sprintf("chats/%d", chatID), values, update)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.EditChat", http.MethodPatch, fmt.Sprintf("chats/%d", chatID), values, update, result)
 }
 
 //AddMember adds members to chat. Additional permissions may require.
 func (a *chats) AddMember(chatID int64, users schemes.UserIdsList) (*schemes.SimpleQueryResult, error) {
+	return a.AddMemberWithContext(context.Background(), chatID, users)
+}
+
+//AddMemberWithContext is AddMember bound to ctx
+func (a *chats) AddMemberWithContext(ctx context.Context, chatID int64, users schemes.UserIdsList) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodPost, fmt.Sprintf("chats/%d/members", chatID), values, users)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.AddMember", http.MethodPost, fmt.Sprintf("chats/%d/members", chatID), values, users, result)
 }
 
 //RemoveMember removes member from chat. Additional permissions may require.
 func (a *chats) RemoveMember(chatID int64, userID int64) (*schemes.SimpleQueryResult, error) {
+	return a.RemoveMemberWithContext(context.Background(), chatID, userID)
+}
+
+//RemoveMemberWithContext is RemoveMember bound to ctx
+func (a *chats) RemoveMemberWithContext(ctx context.Context, chatID int64, userID int64) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
 	values.Set("user_id", strconv.Itoa(int(userID)))
-	body, err := a.client.request(http.MethodDelete, fmt.Sprintf("chats/%d/members", chatID), values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.RemoveMember", http.MethodDelete, fmt.Sprintf("chats/%d/members", chatID), values, nil, result)
 }
 
 //SendAction send bot action to chat
 func (a *chats) SendAction(chatID int64, action schemes.SenderAction) (*schemes.SimpleQueryResult, error) {
+	return a.SendActionWithContext(context.Background(), chatID, action)
+}
+
+//SendActionWithContext is SendAction bound to ctx
+func (a *chats) SendActionWithContext(ctx context.Context, chatID int64, action schemes.SenderAction) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodPost, fmt.Sprintf("chats/%d/actions", chatID), values, schemes.ActionRequestBody{Action: action})
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Chats.SendAction", http.MethodPost, fmt.Sprintf("chats/%d/actions", chatID), values, schemes.ActionRequestBody{Action: action}, result)
 }
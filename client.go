@@ -2,65 +2,220 @@ package tamtam
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/neonxp/tamtam/schemes"
 )
 
-var (
-	errLongPollTimeout = errors.New("timeout")
-)
+const maxErrorBody = 4 << 10
 
 type client struct {
-	key        string
-	version    string
-	url        *url.URL
-	httpClient *http.Client
+	key           string
+	version       string
+	userAgent     string
+	url           *url.URL
+	httpClient    *http.Client
+	retry         RetryPolicy
+	limiter       *rateLimiter
+	logger        Logger
+	handler       CallHandler
+	tokenInHeader bool
+	redactor      *redactor
+}
+
+func newClient(key string, o *options) *client {
+	cl := &client{
+		key:           key,
+		version:       o.version,
+		userAgent:     o.userAgent,
+		url:           o.url,
+		httpClient:    o.httpClient,
+		retry:         o.retry,
+		tokenInHeader: o.tokenInHeader,
+		redactor:      newRedactor(key),
+	}
+	cl.logger = &redactingLogger{logger: o.logger, redactor: cl.redactor}
+	if o.rateLimit != nil {
+		cl.limiter = newRateLimiter(*o.rateLimit)
+	}
+	cl.handler = chainMiddleware(cl.doCall, o.middleware)
+	return cl
+}
+
+//call performs API call through middleware chain and decodes response into result
+func (cl *client) call(ctx context.Context, name, method, path string, query url.Values, body interface{}, result interface{}) error {
+	return cl.handler(ctx, &Call{Name: name, Method: method, Path: path, Query: query, Body: body, Result: result})
 }
 
-func newClient(key string, version string, url *url.URL, httpClient *http.Client) *client {
-	return &client{key: key, version: version, url: url, httpClient: httpClient}
+func (cl *client) doCall(ctx context.Context, call *Call) error {
+	body, err := cl.request(ctx, call.Method, call.Path, call.Query, call.Body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			cl.logger.Warn("close response body", "err", err)
+		}
+	}()
+	if call.Result == nil {
+		return nil
+	}
+	return json.NewDecoder(body).Decode(call.Result)
 }
 
-func (cl *client) request(method, path string, query url.Values, body interface{}) (io.ReadCloser, error) {
+func (cl *client) request(ctx context.Context, method, path string, query url.Values, body interface{}) (io.ReadCloser, error) {
 	j, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
-	return cl.requestReader(method, path, query, bytes.NewReader(j))
+	return cl.requestReader(ctx, method, path, query, bytes.NewReader(j))
 }
 
-func (cl *client) requestReader(method, path string, query url.Values, body io.Reader) (io.ReadCloser, error) {
+func (cl *client) requestReader(ctx context.Context, method, path string, query url.Values, body io.Reader) (io.ReadCloser, error) {
 	u := *cl.url
-	u.Path = path
-	query.Set("access_token", cl.key)
-	query.Set("v", cl.version)
-	u.RawQuery = query.Encode()
-	req, err := http.NewRequest(method, u.String(), body)
+	u.Path = strings.TrimRight(cl.url.Path, "/") + "/" + path
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if !cl.tokenInHeader {
+		q.Set("access_token", cl.key)
+	}
+	q.Set("v", cl.version)
+	u.RawQuery = q.Encode()
+	retry := cl.retry.enabled() && cl.retry.allowsMethod(method)
+	var payload []byte
+	if retry && body != nil {
+		// Body is buffered to be replayed on every attempt
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = b
+	}
+	for attempt := 1; ; attempt++ {
+		if cl.limiter != nil {
+			if err := cl.limiter.wait(ctx, query); err != nil {
+				return nil, err
+			}
+		}
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		start := time.Now()
+		resp, err := cl.do(ctx, method, path, u.String(), body)
+		cl.logRequest(method, path, query, attempt, start, resp, err)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return resp.Body, nil
+		}
+		if !retry || attempt >= cl.retry.MaxAttempts || !cl.retryable(resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			return nil, cl.decodeError(method, path, resp)
+		}
+		delay := cl.retry.backoff(attempt, resp)
+		if resp != nil {
+			err = cl.decodeError(method, path, resp)
+		}
+		cl.logger.Warn("api call retry", "method", method, "path", path, "attempt", attempt, "delay", delay, "err", err)
+		if cl.retry.OnRetry != nil {
+			e := RetryEvent{Method: method, Path: path, Attempt: attempt, Err: err, Delay: delay}
+			if resp != nil {
+				e.StatusCode = resp.StatusCode
+			}
+			cl.retry.OnRetry(e)
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//do performs single attempt of request. Long poll requests are bound by their context deadline, not by http client timeout
+func (cl *client) do(ctx context.Context, method, path, u string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, body)
 	if err != nil {
-		return nil, err
+		return nil, cl.redactor.error(err)
 	}
-	resp, err := cl.httpClient.Do(req)
+	req = req.WithContext(ctx)
+	cl.setHeaders(req)
+	if cl.tokenInHeader {
+		req.Header.Set("Authorization", cl.key)
+	}
+	httpClient := cl.httpClient
+	if isLongPoll(ctx) {
+		httpClient = cl.transferClient()
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		err, ok := err.(*url.Error)
-		if ok {
-			if err.Timeout() {
-				return nil, errLongPollTimeout
-			}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		err = cl.redactor.error(err)
+		if uerr, ok := err.(*url.Error); ok && uerr.Timeout() {
+			return nil, &TimeoutError{Method: method, Path: "/" + path, Err: err}
 		}
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		errObj := new(schemes.Error)
-		err = json.NewDecoder(resp.Body).Decode(errObj)
-		if err != nil {
-			return nil, err
+	return resp, nil
+}
+
+func (cl *client) logRequest(method, path string, query url.Values, attempt int, start time.Time, resp *http.Response, err error) {
+	args := []interface{}{"method", method, "path", path}
+	for _, k := range []string{"chat_id", "user_id"} {
+		if v := query.Get(k); v != "" {
+			args = append(args, k, v)
 		}
-		return nil, errObj
 	}
-	return resp.Body, err
+	args = append(args, "attempt", attempt, "latency", time.Since(start))
+	if err != nil {
+		cl.logger.Debug("api call failed", append(args, "err", err)...)
+		return
+	}
+	cl.logger.Debug("api call", append(args, "status", resp.StatusCode)...)
+}
+
+func (cl *client) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrTimeout) && err != context.Canceled && err != context.DeadlineExceeded
+	}
+	return cl.retry.retryableStatus(resp.StatusCode)
+}
+
+//decodeError converts unsuccessful response to *APIError. Body that is not JSON becomes error message
+func (cl *client) decodeError(method, path string, resp *http.Response) error {
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return err
+	}
+	errObj := new(schemes.Error)
+	if err := json.Unmarshal(b, errObj); err != nil {
+		apiErr := newAPIError(method, path, resp.StatusCode, nil)
+		apiErr.Message = strings.TrimSpace(string(b))
+		return apiErr
+	}
+	return newAPIError(method, path, resp.StatusCode, errObj)
+}
+
+//transferClient returns http client for long polling, uploads and downloads. It shares transport with API client, but has no timeout because such requests are bound by context only
+func (cl *client) transferClient() *http.Client {
+	c := *cl.httpClient
+	c.Timeout = 0
+	return &c
+}
+
+func (cl *client) setHeaders(req *http.Request) {
+	if cl.userAgent != "" {
+		req.Header.Set("User-Agent", cl.userAgent)
+	}
 }
@@ -0,0 +1,346 @@
+package tamtam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+const helpCommand = "help"
+
+//ErrUnterminatedQuote returned when command arguments have quote without pair
+var ErrUnterminatedQuote = errors.New("tamtam: unterminated quote in command arguments")
+
+//ArgType is type of command argument
+type ArgType int
+
+//List of ArgType
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgFloat
+	ArgBool
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case ArgInt:
+		return "integer"
+	case ArgFloat:
+		return "number"
+	case ArgBool:
+		return "yes/no"
+	}
+	return "text"
+}
+
+//Arg describes command argument. Arguments are separated by spaces, quotes group words into one argument
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool // Optional arguments must follow required ones
+	Rest     bool // Last argument may take the rest of text
+}
+
+//Command describes command handled by bot
+type Command struct {
+	Name        string // Name without slash
+	Description string // Shown in /help and in TamTam command list
+	Args        []Arg
+	Handler     func(c *Context[*schemes.MessageCreatedUpdate], args *Args) error
+}
+
+func (cmd *Command) usage() string {
+	b := strings.Builder{}
+	b.WriteString("/" + cmd.Name)
+	for _, arg := range cmd.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+		if arg.Optional {
+			b.WriteString(" [" + name + "]")
+		} else {
+			b.WriteString(" <" + name + ">")
+		}
+	}
+	return b.String()
+}
+
+//parse splits text into words and converts them to typed arguments. Rest argument takes the remaining text as it was typed
+func (cmd *Command) parse(text string) (*Args, error) {
+	args := &Args{values: map[string]interface{}{}}
+	rest := strings.TrimSpace(text)
+	for _, arg := range cmd.Args {
+		if rest == "" {
+			if !arg.Optional {
+				return nil, fmt.Errorf("missing argument %s", arg.Name)
+			}
+			continue
+		}
+		var word string
+		if arg.Rest {
+			word, rest = rest, ""
+		} else {
+			var err error
+			if word, rest, err = nextWord(rest); err != nil {
+				return nil, err
+			}
+		}
+		args.Raw = append(args.Raw, word)
+		v, err := parseArg(arg.Type, word)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s must be %s", arg.Name, arg.Type)
+		}
+		args.values[arg.Name] = v
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("too many arguments")
+	}
+	return args, nil
+}
+
+func parseArg(t ArgType, s string) (interface{}, error) {
+	switch t {
+	case ArgInt:
+		return strconv.ParseInt(s, 10, 64)
+	case ArgFloat:
+		return strconv.ParseFloat(s, 64)
+	case ArgBool:
+		switch strings.ToLower(s) {
+		case "yes", "on":
+			return true, nil
+		case "no", "off":
+			return false, nil
+		}
+		return strconv.ParseBool(s)
+	}
+	return s, nil
+}
+
+//Args holds parsed command arguments
+type Args struct {
+	Raw    []string // Given arguments without quotes. Rest argument is kept as it was typed
+	values map[string]interface{}
+}
+
+//Has reports whether argument is given
+func (a *Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+//String returns value of string argument or empty string
+func (a *Args) String(name string) string {
+	v, _ := a.values[name].(string)
+	return v
+}
+
+//Int returns value of integer argument or 0
+func (a *Args) Int(name string) int64 {
+	v, _ := a.values[name].(int64)
+	return v
+}
+
+//Float returns value of number argument or 0
+func (a *Args) Float(name string) float64 {
+	v, _ := a.values[name].(float64)
+	return v
+}
+
+//Bool returns value of yes/no argument or false
+func (a *Args) Bool(name string) bool {
+	v, _ := a.values[name].(bool)
+	return v
+}
+
+//Commands is registry of bot commands. It dispatches commands to handlers, answers /help and keeps command list shown by TamTam in sync
+type Commands struct {
+	api      *Api
+	mu       sync.RWMutex // Guards commands, byName and username
+	commands []*Command
+	byName   map[string]*Command
+	username string
+}
+
+//NewCommands returns registry with built-in /help command. Define command "help" to replace it
+func (a *Api) NewCommands() *Commands {
+	return &Commands{api: a, byName: map[string]*Command{}}
+}
+
+//Add registers command. It panics if command has no handler or command with same name is already registered
+func (cs *Commands) Add(cmd Command) *Commands {
+	cmd.Name = strings.TrimPrefix(cmd.Name, "/")
+	if cmd.Handler == nil {
+		panic("tamtam: command /" + cmd.Name + " has no handler")
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.byName[cmd.Name]; ok {
+		panic("tamtam: command /" + cmd.Name + " is already registered")
+	}
+	cs.commands = append(cs.commands, &cmd)
+	cs.byName[cmd.Name] = &cmd
+	return cs
+}
+
+//Help returns list of commands with arguments and descriptions
+func (cs *Commands) Help() string {
+	commands := cs.all()
+	lines := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		line := cmd.usage()
+		if cmd.Description != "" {
+			line += " — " + cmd.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+//BotCommands returns command list in form accepted by Bots.PatchBot
+func (cs *Commands) BotCommands() []schemes.BotCommand {
+	commands := cs.all()
+	result := make([]schemes.BotCommand, 0, len(commands))
+	for _, cmd := range commands {
+		result = append(result, schemes.BotCommand{Name: cmd.Name, Description: cmd.Description})
+	}
+	return result
+}
+
+//Sync remembers bot username for /cmd@botname forms and updates command list shown by TamTam if it differs from registered one
+func (cs *Commands) Sync(ctx context.Context) error {
+	bot, err := cs.api.Bots.GetBotWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	cs.username = bot.Username
+	cs.mu.Unlock()
+	commands := cs.BotCommands()
+	if sameCommands(bot.Commands, commands) {
+		return nil
+	}
+	cs.api.client.logger.Info("updating bot commands", "count", len(commands))
+	_, err = cs.api.Bots.PatchBotWithContext(ctx, &schemes.BotPatch{Commands: commands})
+	return err
+}
+
+//Match accepts new messages with registered commands. Commands addressed to other bots as /cmd@otherbot are not accepted once Sync has learned bot username, before that they are accepted as well
+func (cs *Commands) Match(upd schemes.UpdateInterface) bool {
+	cmd, _ := cs.lookup(messageText(upd))
+	return cmd != nil
+}
+
+//HandleUpdate parses command arguments and calls command handler. On wrong arguments it replies with command usage. Use it with Match: router.Route(commands.HandleUpdate, commands.Match)
+func (cs *Commands) HandleUpdate(ctx context.Context, upd schemes.UpdateInterface) error {
+	m, ok := upd.(*schemes.MessageCreatedUpdate)
+	if !ok {
+		return nil
+	}
+	cmd, text := cs.lookup(m.Message.Body.Text)
+	if cmd == nil {
+		return nil
+	}
+	c := &Context[*schemes.MessageCreatedUpdate]{Context: ctx, Api: cs.api, Update: m}
+	args, err := cmd.parse(text)
+	if err == ErrUnterminatedQuote {
+		return c.Reply("Usage: " + cmd.usage())
+	}
+	if err != nil {
+		return c.Reply(err.Error() + "\nUsage: " + cmd.usage())
+	}
+	return cmd.Handler(c, args)
+}
+
+//lookup returns command of text and text of its arguments
+func (cs *Commands) lookup(text string) (*Command, string) {
+	name, bot, args := parseCommand(text)
+	if name == "" {
+		return nil, ""
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if bot != "" && cs.username != "" && !strings.EqualFold(bot, cs.username) {
+		return nil, ""
+	}
+	if cmd, ok := cs.byName[name]; ok {
+		return cmd, args
+	}
+	if name == helpCommand {
+		return cs.help(), args
+	}
+	return nil, ""
+}
+
+//all returns copy of registered commands and built-in help, if it is not replaced
+func (cs *Commands) all() []*Command {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	commands := append(make([]*Command, 0, len(cs.commands)+1), cs.commands...)
+	if _, ok := cs.byName[helpCommand]; ok {
+		return commands
+	}
+	return append(commands, cs.help())
+}
+
+func (cs *Commands) help() *Command {
+	return &Command{
+		Name:        helpCommand,
+		Description: "list of commands",
+		Handler: func(c *Context[*schemes.MessageCreatedUpdate], args *Args) error {
+			return c.Reply(cs.Help())
+		},
+	}
+}
+
+func sameCommands(a, b []schemes.BotCommand) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//nextWord returns the first word of text and text after it. Word is delimited by spaces unless it starts with single or double quote: then it lasts until the pair quote. Backslash escapes next character
+func nextWord(text string) (string, string, error) {
+	var (
+		word    strings.Builder
+		quote   rune
+		escaped bool
+	)
+	for i, r := range text {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case i == 0 && (r == '"' || r == '\''):
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n':
+			return word.String(), strings.TrimSpace(text[i:]), nil
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if quote != 0 || escaped {
+		return "", "", ErrUnterminatedQuote
+	}
+	return word.String(), "", nil
+}
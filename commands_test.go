@@ -0,0 +1,81 @@
+package tamtam
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNextWord(t *testing.T) {
+	tests := []struct {
+		text string
+		word string
+		rest string
+		err  error
+	}{
+		{text: "", word: "", rest: ""},
+		{text: "one", word: "one", rest: ""},
+		{text: "one two  three", word: "one", rest: "two  three"},
+		{text: "one\ttwo", word: "one", rest: "two"},
+		{text: `"two words" three`, word: "two words", rest: "three"},
+		{text: `'two words' three`, word: "two words", rest: "three"},
+		{text: `'say "hi"'`, word: `say "hi"`, rest: ""},
+		{text: `don't stop`, word: "don't", rest: "stop"},
+		{text: `5" screen`, word: `5"`, rest: "screen"},
+		{text: `two\ words`, word: "two words", rest: ""},
+		{text: `"quoted \" quote"`, word: `quoted " quote`, rest: ""},
+		{text: `"unterminated`, err: ErrUnterminatedQuote},
+		{text: `trailing\`, err: ErrUnterminatedQuote},
+	}
+	for _, tt := range tests {
+		word, rest, err := nextWord(tt.text)
+		if err != tt.err {
+			t.Errorf("nextWord(%q): expected error %v, got %v", tt.text, tt.err, err)
+			continue
+		}
+		if word != tt.word || rest != tt.rest {
+			t.Errorf("nextWord(%q) = %q, %q; expected %q, %q", tt.text, word, rest, tt.word, tt.rest)
+		}
+	}
+}
+
+func TestCommandParse(t *testing.T) {
+	cmd := &Command{Name: "remind", Args: []Arg{
+		{Name: "minutes", Type: ArgInt},
+		{Name: "loud", Type: ArgBool, Optional: true},
+		{Name: "text", Optional: true, Rest: true},
+	}}
+	tests := []struct {
+		text   string
+		values map[string]interface{}
+		raw    []string
+		err    bool
+	}{
+		{text: "5", values: map[string]interface{}{"minutes": int64(5)}, raw: []string{"5"}},
+		{text: "  5  yes ", values: map[string]interface{}{"minutes": int64(5), "loud": true}, raw: []string{"5", "yes"}},
+		{text: `5 off don't  "forget" it`, values: map[string]interface{}{"minutes": int64(5), "loud": false, "text": `don't  "forget" it`}, raw: []string{"5", "off", `don't  "forget" it`}},
+		{text: `"5" on text`, values: map[string]interface{}{"minutes": int64(5), "loud": true, "text": "text"}, raw: []string{"5", "on", "text"}},
+		{text: "", err: true},
+		{text: "five", err: true},
+		{text: "5 maybe", err: true},
+		{text: `"5`, err: true},
+	}
+	for _, tt := range tests {
+		args, err := cmd.parse(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parse(%q): expected error", tt.text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(args.values, tt.values) || !reflect.DeepEqual(args.Raw, tt.raw) {
+			t.Errorf("parse(%q) = %v, %q; expected %v, %q", tt.text, args.values, args.Raw, tt.values, tt.raw)
+		}
+	}
+	if _, err := (&Command{Name: "ping"}).parse("extra"); err == nil {
+		t.Error("expected error for argument of command without arguments")
+	}
+}
@@ -0,0 +1,204 @@
+package tamtam
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+//UpdateDecoder decodes raw JSON of update
+type UpdateDecoder func(b []byte) (schemes.UpdateInterface, error)
+
+//AttachmentDecoder decodes raw JSON of attachment
+type AttachmentDecoder func(b []byte) (schemes.AttachmentInterface, error)
+
+var errNilDecoded = errors.New("decoder returned nil without error")
+
+type decoders struct {
+	updates     map[schemes.UpdateType]UpdateDecoder
+	attachments map[schemes.AttachmentType]AttachmentDecoder
+}
+
+//WithUpdateDecoder registers decoder for update type. It takes precedence over built-in decoding, so it can be used both for types unknown to the library and to override known ones
+func WithUpdateDecoder(updateType schemes.UpdateType, decoder UpdateDecoder) Option {
+	return func(o *options) {
+		if o.decoders.updates == nil {
+			o.decoders.updates = map[schemes.UpdateType]UpdateDecoder{}
+		}
+		o.decoders.updates[updateType] = decoder
+	}
+}
+
+//WithAttachmentDecoder registers decoder for attachment type. It takes precedence over built-in decoding
+func WithAttachmentDecoder(attachmentType schemes.AttachmentType, decoder AttachmentDecoder) Option {
+	return func(o *options) {
+		if o.decoders.attachments == nil {
+			o.decoders.attachments = map[schemes.AttachmentType]AttachmentDecoder{}
+		}
+		o.decoders.attachments[attachmentType] = decoder
+	}
+}
+
+//DecodeError describes update or attachment that could not be decoded
+type DecodeError struct {
+	Type string          // Update or attachment type
+	Raw  json.RawMessage // Original JSON
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Type == "" {
+		return "tamtam: decode: " + e.Err.Error()
+	}
+	return "tamtam: decode " + e.Type + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//DecodeUpdate converts raw update JSON to typed update. Updates of unknown types become *schemes.UnknownUpdate keeping raw JSON.
+//On error returned update is still usable: it is *schemes.UnknownUpdate or update with *schemes.UnknownAttachment in place of broken attachment
+func (a *Api) DecodeUpdate(b []byte) (schemes.UpdateInterface, error) {
+	u := new(schemes.Update)
+	if err := json.Unmarshal(b, u); err != nil {
+		return schemes.NewUnknownUpdate(b), &DecodeError{Raw: b, Err: err}
+	}
+	var upd schemes.UpdateInterface
+	if dec, ok := a.decoders.updates[u.UpdateType]; ok {
+		res, err := dec(b)
+		if err == nil && res == nil {
+			err = errNilDecoded
+		}
+		if err != nil {
+			return schemes.NewUnknownUpdate(b), &DecodeError{Type: string(u.UpdateType), Raw: b, Err: err}
+		}
+		upd = res
+	} else {
+		upd = newUpdate(u.UpdateType)
+		if upd == nil {
+			return schemes.NewUnknownUpdate(b), nil
+		}
+		if err := json.Unmarshal(b, upd); err != nil {
+			return schemes.NewUnknownUpdate(b), &DecodeError{Type: string(u.UpdateType), Raw: b, Err: err}
+		}
+	}
+	return upd, a.decodeAttachments(updateMessageBody(upd))
+}
+
+//DecodeAttachment converts raw attachment JSON to typed attachment. Attachments of unknown types become *schemes.UnknownAttachment
+func (a *Api) DecodeAttachment(b []byte) (schemes.AttachmentInterface, error) {
+	attachment := new(schemes.Attachment)
+	if err := json.Unmarshal(b, attachment); err != nil {
+		return schemes.NewUnknownAttachment(b), &DecodeError{Raw: b, Err: err}
+	}
+	if dec, ok := a.decoders.attachments[attachment.Type]; ok {
+		res, err := dec(b)
+		if err == nil && res == nil {
+			err = errNilDecoded
+		}
+		if err != nil {
+			return schemes.NewUnknownAttachment(b), &DecodeError{Type: string(attachment.Type), Raw: b, Err: err}
+		}
+		return res, nil
+	}
+	res := newAttachment(attachment.Type)
+	if res == nil {
+		return schemes.NewUnknownAttachment(b), nil
+	}
+	if err := json.Unmarshal(b, res); err != nil {
+		return schemes.NewUnknownAttachment(b), &DecodeError{Type: string(attachment.Type), Raw: b, Err: err}
+	}
+	return res, nil
+}
+
+//decodeAttachments fills Attachments of message body from RawAttachments. Returns first error
+func (a *Api) decodeAttachments(body *schemes.MessageBody) error {
+	if body == nil {
+		return nil
+	}
+	var firstErr error
+	body.Attachments = make([]interface{}, 0, len(body.RawAttachments))
+	for _, raw := range body.RawAttachments {
+		att, err := a.DecodeAttachment(raw)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		body.Attachments = append(body.Attachments, att)
+	}
+	return firstErr
+}
+
+func newUpdate(updateType schemes.UpdateType) schemes.UpdateInterface {
+	switch updateType {
+	case schemes.TypeMessageCallback:
+		return new(schemes.MessageCallbackUpdate)
+	case schemes.TypeMessageCreated:
+		return new(schemes.MessageCreatedUpdate)
+	case schemes.TypeMessageRemoved:
+		return new(schemes.MessageRemovedUpdate)
+	case schemes.TypeMessageEdited:
+		return new(schemes.MessageEditedUpdate)
+	case schemes.TypeBotAdded:
+		return new(schemes.BotAddedToChatUpdate)
+	case schemes.TypeBotRemoved:
+		return new(schemes.BotRemovedFromChatUpdate)
+	case schemes.TypeUserAdded:
+		return new(schemes.UserAddedToChatUpdate)
+	case schemes.TypeUserRemoved:
+		return new(schemes.UserRemovedFromChatUpdate)
+	case schemes.TypeBotStarted:
+		return new(schemes.BotStartedUpdate)
+	case schemes.TypeChatTitleChanged:
+		return new(schemes.ChatTitleChangedUpdate)
+	case schemes.TypeMessageConstructionRequest:
+		return new(schemes.MessageConstructionRequestUpdate)
+	case schemes.TypeMessageConstructed:
+		return new(schemes.MessageConstructedUpdate)
+	case schemes.TypeMessageChatCreated:
+		return new(schemes.MessageChatCreatedUpdate)
+	}
+	return nil
+}
+
+//updateMessageBody returns body of message carried by update, if any
+func updateMessageBody(upd schemes.UpdateInterface) *schemes.MessageBody {
+	switch upd := upd.(type) {
+	case *schemes.MessageCreatedUpdate:
+		return &upd.Message.Body
+	case *schemes.MessageEditedUpdate:
+		return &upd.Message.Body
+	case *schemes.MessageCallbackUpdate:
+		if upd.Message != nil {
+			return &upd.Message.Body
+		}
+	case *schemes.MessageConstructedUpdate:
+		return &upd.Message.Body
+	}
+	return nil
+}
+
+func newAttachment(attachmentType schemes.AttachmentType) schemes.AttachmentInterface {
+	switch attachmentType {
+	case schemes.AttachmentAudio:
+		return new(schemes.AudioAttachment)
+	case schemes.AttachmentContact:
+		return new(schemes.ContactAttachment)
+	case schemes.AttachmentFile:
+		return new(schemes.FileAttachment)
+	case schemes.AttachmentImage:
+		return new(schemes.PhotoAttachment)
+	case schemes.AttachmentKeyboard:
+		return new(schemes.InlineKeyboardAttachment)
+	case schemes.AttachmentLocation:
+		return new(schemes.LocationAttachment)
+	case schemes.AttachmentShare:
+		return new(schemes.ShareAttachment)
+	case schemes.AttachmentSticker:
+		return new(schemes.StickerAttachment)
+	case schemes.AttachmentVideo:
+		return new(schemes.VideoAttachment)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package tamtam
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+const defaultDedupWindow = 10 * time.Minute
+
+//DedupStore remembers keys of updates already dispatched
+type DedupStore interface {
+	//Seen records key for window and reports whether it had been recorded before and not expired yet
+	Seen(key string, window time.Duration) (bool, error)
+	//Forget removes key, so update with it is not considered repeated anymore
+	Forget(key string) error
+}
+
+//DedupConfig enables dropping of repeated updates in Dispatcher. Zero values mean defaults
+type DedupConfig struct {
+	Window time.Duration                            // How long update key is remembered. Defaults to 10 minutes
+	Store  DedupStore                               // Where keys are remembered. Defaults to new in-memory store
+	Key    func(upd schemes.UpdateInterface) string // Derives key of update. Defaults to UpdateKey. Empty key means update is never dropped
+}
+
+func (c DedupConfig) withDefaults() DedupConfig {
+	if c.Window <= 0 {
+		c.Window = defaultDedupWindow
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryDedupStore()
+	}
+	if c.Key == nil {
+		c.Key = UpdateKey
+	}
+	return c
+}
+
+//UpdateKey returns stable key of update: message id for new messages, callback id for callbacks and type, time and chat for the rest
+func UpdateKey(upd schemes.UpdateInterface) string {
+	switch upd := upd.(type) {
+	case *schemes.MessageCreatedUpdate:
+		if upd.Message.Body.Mid != "" {
+			return string(upd.UpdateType) + ":" + upd.Message.Body.Mid
+		}
+	case *schemes.MessageCallbackUpdate:
+		if upd.Callback.CallbackID != "" {
+			return string(upd.UpdateType) + ":" + upd.Callback.CallbackID
+		}
+	case *schemes.MessageEditedUpdate:
+		if upd.Message.Body.Mid != "" {
+			// Message can be edited many times, so time of edit is part of key
+			return string(upd.UpdateType) + ":" + upd.Message.Body.Mid + ":" + strconv.Itoa(upd.Timestamp)
+		}
+	case *schemes.MessageRemovedUpdate:
+		return string(upd.UpdateType) + ":" + upd.MessageId
+	}
+	return string(upd.GetUpdateType()) + ":" + strconv.FormatInt(upd.GetUpdateTime().UnixNano()/int64(time.Millisecond), 10) + ":" + strconv.FormatInt(upd.GetChatID(), 10) + ":" + strconv.FormatInt(upd.GetUserID(), 10)
+}
+
+//MemoryDedupStore keeps keys in memory. Expired keys are swept periodically
+type MemoryDedupStore struct {
+	mu        sync.Mutex
+	keys      map[string]time.Time
+	lastSweep time.Time
+}
+
+//NewMemoryDedupStore returns empty in-memory store
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{keys: map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+//Seen records key for window and reports whether it had been recorded before
+func (s *MemoryDedupStore) Seen(key string, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > window {
+		for k, exp := range s.keys {
+			if now.After(exp) {
+				delete(s.keys, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if exp, ok := s.keys[key]; ok && now.Before(exp) {
+		return true, nil
+	}
+	s.keys[key] = now.Add(window)
+	return false, nil
+}
+
+//Forget removes key
+func (s *MemoryDedupStore) Forget(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
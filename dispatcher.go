@@ -0,0 +1,282 @@
+package tamtam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+const defaultDispatcherQueue = 100
+
+var (
+	ErrQueueFull        = errors.New("tamtam: dispatcher queue is full")
+	ErrDispatcherClosed = errors.New("tamtam: dispatcher is closed")
+)
+
+//UpdateHandler processes single update
+type UpdateHandler func(ctx context.Context, upd schemes.UpdateInterface) error
+
+//DispatcherConfig configures Dispatcher. Zero values mean defaults
+type DispatcherConfig struct {
+	Workers   int                                          // Number of workers. Defaults to number of CPUs
+	QueueSize int                                          // Maximum number of updates waiting for each worker. Defaults to 100
+	FailFast  bool                                         // If set, Dispatch returns ErrQueueFull instead of waiting for free place in queue
+	OnError   func(upd schemes.UpdateInterface, err error) // Called when handler returns error or panics, and when Run drops update. Such updates are not marked done in journal until they fail JournalConfig.MaxAttempts times and are reported as *JournalDropError
+	Dedup     *DedupConfig                                 // If set, updates already dispatched within dedup window are dropped. Keys of failed updates are forgotten
+}
+
+//PanicError is reported to OnError when handler panics
+type PanicError struct {
+	Value interface{} // Value passed to panic
+	Stack []byte      // Stack trace of panicked goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("tamtam: handler panic: %v", e.Value)
+}
+
+//Dispatcher processes updates with bounded pool of workers. Updates of different chats are handled in parallel, updates of the same chat (or the same user, if update has no chat) are handled strictly in order they were dispatched
+type Dispatcher struct {
+	api     *Api
+	cfg     DispatcherConfig
+	handler UpdateHandler
+	queues  []chan schemes.UpdateInterface
+	next    uint32
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.RWMutex
+	closed  bool
+	active  sync.WaitGroup
+	workers sync.WaitGroup
+	done    chan struct{}
+}
+
+//NewDispatcher returns dispatcher calling handler for every update. Workers are started immediately and stopped by Close
+func (a *Api) NewDispatcher(cfg DispatcherConfig, handler UpdateHandler) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultDispatcherQueue
+	}
+	if cfg.Dedup != nil {
+		dedup := cfg.Dedup.withDefaults()
+		cfg.Dedup = &dedup
+	}
+	d := &Dispatcher{api: a, cfg: cfg, handler: handler, queues: make([]chan schemes.UpdateInterface, cfg.Workers)}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for i := range d.queues {
+		d.queues[i] = make(chan schemes.UpdateInterface, cfg.QueueSize)
+		d.workers.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+//Dispatch puts update into queue of its chat worker. If queue is full, it waits for free place until ctx is done, or returns ErrQueueFull when FailFast is set. Repeated updates are silently dropped when dedup is enabled
+func (d *Dispatcher) Dispatch(ctx context.Context, upd schemes.UpdateInterface) (err error) {
+	key, dup := d.duplicate(upd)
+	if dup {
+		d.markDone(upd)
+		return nil
+	}
+	if key != "" {
+		defer func() {
+			if err != nil {
+				d.forget(key)
+			}
+		}()
+	}
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return ErrDispatcherClosed
+	}
+	d.active.Add(1)
+	d.mu.RUnlock()
+	defer d.active.Done()
+	q := d.queues[d.shard(upd)]
+	if d.cfg.FailFast {
+		select {
+		case q <- upd:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+	select {
+	case q <- upd:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.ctx.Done():
+		return ErrDispatcherClosed
+	}
+}
+
+//Run dispatches updates from channel until it is closed or ctx is done. Updates that could not be queued are reported to OnError. Run does not close dispatcher
+func (d *Dispatcher) Run(ctx context.Context, updates <-chan schemes.UpdateInterface) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case upd, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := d.Dispatch(ctx, upd); err != nil {
+				if err == ErrQueueFull {
+					d.api.client.logger.Warn("dispatcher queue is full", "update_type", upd.GetUpdateType(), "chat_id", upd.GetChatID())
+					if d.api.journal != nil {
+						// Dropped update stays in journal and is replayed on next start
+						d.api.journal.release(upd)
+					}
+					d.reportError(upd, err)
+					continue
+				}
+				return err
+			}
+		}
+	}
+}
+
+//Pending returns number of updates waiting in queues
+func (d *Dispatcher) Pending() int {
+	n := 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	return n
+}
+
+//Close stops accepting updates and waits until queued ones are handled. If ctx is done earlier, context passed to handlers is canceled and ctx error is returned
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		d.done = make(chan struct{})
+		go func() {
+			d.active.Wait()
+			for _, q := range d.queues {
+				close(q)
+			}
+			d.workers.Wait()
+			close(d.done)
+		}()
+	}
+	d.mu.Unlock()
+	select {
+	case <-d.done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+//duplicate reports whether update has been dispatched already and returns its recorded key. Store errors are logged and update is treated as new one
+func (d *Dispatcher) duplicate(upd schemes.UpdateInterface) (string, bool) {
+	if d.cfg.Dedup == nil {
+		return "", false
+	}
+	key := d.cfg.Dedup.Key(upd)
+	if key == "" {
+		return "", false
+	}
+	seen, err := d.cfg.Dedup.Store.Seen(key, d.cfg.Dedup.Window)
+	if err != nil {
+		d.api.client.logger.Warn("dedup store failed", "key", key, "err", err)
+		return "", false
+	}
+	if seen {
+		d.api.client.logger.Debug("duplicate update dropped", "update_type", upd.GetUpdateType(), "key", key)
+	}
+	return key, seen
+}
+
+//forget removes key of update that was not queued or failed, so its redelivery is not dropped
+func (d *Dispatcher) forget(key string) {
+	if err := d.cfg.Dedup.Store.Forget(key); err != nil {
+		d.api.client.logger.Warn("dedup store failed", "key", key, "err", err)
+	}
+}
+
+//shard returns index of worker for update. Updates without chat and user are spread evenly
+func (d *Dispatcher) shard(upd schemes.UpdateInterface) int {
+	key := upd.GetChatID()
+	if key == 0 {
+		key = upd.GetUserID()
+	}
+	if key == 0 {
+		return int(atomic.AddUint32(&d.next, 1) % uint32(len(d.queues)))
+	}
+	h := uint64(key) * 0x9E3779B97F4A7C15
+	return int(h % uint64(len(d.queues)))
+}
+
+func (d *Dispatcher) work(q <-chan schemes.UpdateInterface) {
+	defer d.workers.Done()
+	for upd := range q {
+		if err := d.handle(upd); err != nil {
+			if d.cfg.Dedup != nil {
+				// Failed update is not a duplicate when it is delivered or replayed again
+				if key := d.cfg.Dedup.Key(upd); key != "" {
+					d.forget(key)
+				}
+			}
+			if d.api.journal != nil {
+				err = d.fail(upd, err)
+			}
+			d.reportError(upd, err)
+			continue
+		}
+		d.markDone(upd)
+	}
+}
+
+//markDone marks update handled in journal, if it is enabled
+func (d *Dispatcher) markDone(upd schemes.UpdateInterface) {
+	if d.api.journal == nil {
+		return
+	}
+	if err := d.api.journal.Done(upd); err != nil {
+		d.api.client.logger.Error("journal update done", "update_type", upd.GetUpdateType(), "err", err)
+	}
+}
+
+//fail counts failed attempt in journal. Failed update stays in journal and is replayed on next start until it runs out of attempts, then it is dropped and *JournalDropError is returned
+func (d *Dispatcher) fail(upd schemes.UpdateInterface, err error) error {
+	raw, attempts, jerr := d.api.journal.fail(upd)
+	if jerr != nil {
+		d.api.client.logger.Error("journal update failed", "update_type", upd.GetUpdateType(), "err", jerr)
+	}
+	if raw == nil {
+		return err
+	}
+	d.api.client.logger.Warn("update dropped from journal", "update_type", upd.GetUpdateType(), "attempts", attempts, "err", err)
+	return &JournalDropError{Update: raw, Err: err}
+}
+
+//handle calls handler isolating its panic
+func (d *Dispatcher) handle(upd schemes.UpdateInterface) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+			d.api.client.logger.Error("update handler panic", "update_type", upd.GetUpdateType(), "chat_id", upd.GetChatID(), "panic", v)
+		}
+	}()
+	return d.handler(d.ctx, upd)
+}
+
+func (d *Dispatcher) reportError(upd schemes.UpdateInterface, err error) {
+	if d.cfg.OnError != nil {
+		d.cfg.OnError(upd, err)
+	}
+}
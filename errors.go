@@ -0,0 +1,117 @@
+package tamtam
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+//Sentinel errors to check API errors with errors.Is
+var (
+	ErrBadRequest         = errors.New("tamtam: bad request")
+	ErrUnauthorized       = errors.New("tamtam: unauthorized")
+	ErrForbidden          = errors.New("tamtam: forbidden")
+	ErrNotFound           = errors.New("tamtam: not found")
+	ErrMethodNotAllowed   = errors.New("tamtam: method not allowed")
+	ErrTooManyRequests    = errors.New("tamtam: too many requests")
+	ErrServiceUnavailable = errors.New("tamtam: service unavailable")
+	ErrChatDenied         = errors.New("tamtam: chat denied")
+	ErrAttachmentNotReady = errors.New("tamtam: attachment not ready")
+	ErrTimeout            = errors.New("tamtam: timeout")
+)
+
+//API error codes returned in `code` field
+const (
+	CodeChatDenied         = "chat.denied"
+	CodeAttachmentNotReady = "attachment.not.ready"
+)
+
+//APIError is returned when server answers with an error
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Code       string // API error code, e.g. "chat.denied"
+	Message    string // Human-readable description
+	Method     string // HTTP method of failed request
+	Path       string // Path of failed request. Never contains query and access token
+}
+
+func newAPIError(method, path string, statusCode int, e *schemes.Error) *APIError {
+	err := &APIError{StatusCode: statusCode, Method: method, Path: "/" + strings.TrimPrefix(path, "/")}
+	if e != nil {
+		err.Code = e.Code
+		err.Message = e.Message
+		if err.Message == "" {
+			err.Message = e.ErrorText
+		}
+	}
+	return err
+}
+
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("tamtam: ")
+	if e.Method != "" {
+		sb.WriteString(e.Method + " " + e.Path + ": ")
+	}
+	sb.WriteString(fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)))
+	if e.Code != "" {
+		sb.WriteString(": " + e.Code)
+	}
+	if e.Message != "" {
+		sb.WriteString(": " + e.Message)
+	}
+	return sb.String()
+}
+
+//Is reports whether error matches one of sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrChatDenied:
+		return e.Code == CodeChatDenied
+	case ErrAttachmentNotReady:
+		return e.Code == CodeAttachmentNotReady
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrMethodNotAllowed:
+		return e.StatusCode == http.StatusMethodNotAllowed
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServiceUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+//TimeoutError is returned when API call has not completed in time
+type TimeoutError struct {
+	Method string
+	Path   string
+	Err    error // Underlying transport error
+}
+
+func (e *TimeoutError) Error() string {
+	return "tamtam: " + e.Method + " " + e.Path + ": timeout: " + e.Err.Error()
+}
+
+//Is reports whether target is ErrTimeout
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+//Timeout implements net.Error
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
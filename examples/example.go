@@ -17,7 +17,7 @@ func main() {
 	log.Printf("Get me: %#v %#v", info, err)
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		exit := make(chan os.Signal)
+		exit := make(chan os.Signal, 1)
 		signal.Notify(exit, os.Kill, os.Interrupt)
 		<-exit
 		cancel()
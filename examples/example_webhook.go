@@ -6,10 +6,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/neonxp/tamtam"
 	"github.com/neonxp/tamtam/schemes"
@@ -24,33 +27,52 @@ func main() {
 	info, err := api.Bots.GetBot()
 	log.Printf("Get me: %#v %#v", info, err)
 
-	subs, _ := api.Subscriptions.GetSubscriptions()
-	for _, s := range subs.Subscriptions {
-		_, _ = api.Subscriptions.Unsubscribe(s.Url)
+	// Subscription manager subscribes webhook on start and unsubscribes on stop.
+	// Without HOST it falls back to long polling
+	manager := api.NewSubscriptionManager(tamtam.SubscriptionConfig{
+		PublicURL: host,
+		Webhook: tamtam.WebhookConfig{
+			Path:   "/webhook",
+			Secret: os.Getenv("WEBHOOK_SECRET"),
+		},
+	})
+	if err := manager.Start(context.Background()); err != nil {
+		log.Fatal(err)
 	}
-	subscriptionResp, err := api.Subscriptions.Subscribe(host+"/webhook", []string{})
-	log.Printf("Subscription: %#v %#v", subscriptionResp, err)
 
-	ch := make(chan interface{}) // Channel with updates from TamTam
-
-	http.HandleFunc("/webhook", api.GetHandler(ch))
-	go func() {
-		for {
-			upd := <-ch
-			log.Printf("Received: %#v", upd)
-			switch upd := upd.(type) {
-			case *schemes.MessageCreatedUpdate:
-				err := api.Messages.Send(
-					tamtam.NewMessage().
-						SetUser(upd.Message.Sender.UserId).
-						SetText(fmt.Sprintf("Hello, %s! Your message: %s", upd.Message.Sender.Name, upd.Message.Body.Text)),
-				)
-				log.Printf("Answer: %#v", err)
-			default:
-				log.Printf("Unknown type: %#v", upd)
+	var server *http.Server
+	if webhook := manager.Webhook(); webhook != nil {
+		server = &http.Server{Addr: ":10888", Handler: webhook}
+		go func() {
+			if err := server.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
 			}
+		}()
+	}
+	go func() {
+		exit := make(chan os.Signal, 1)
+		signal.Notify(exit, os.Kill, os.Interrupt)
+		<-exit
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if server != nil {
+			_ = server.Shutdown(ctx)
 		}
+		_ = manager.Stop(ctx)
 	}()
 
-	http.ListenAndServe(":10888", nil)
+	for upd := range manager.Updates() {
+		log.Printf("Received: %#v", upd)
+		switch upd := upd.(type) {
+		case *schemes.MessageCreatedUpdate:
+			err := api.Messages.Send(
+				tamtam.NewMessage().
+					SetUser(upd.Message.Sender.UserId).
+					SetText(fmt.Sprintf("Hello, %s! Your message: %s", upd.Message.Sender.Name, upd.Message.Body.Text)),
+			)
+			log.Printf("Answer: %#v", err)
+		default:
+			log.Printf("Unknown type: %#v", upd)
+		}
+	}
 }
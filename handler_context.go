@@ -0,0 +1,121 @@
+package tamtam
+
+import (
+	"context"
+	"errors"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+var (
+	ErrNotCallback = errors.New("tamtam: update is not a callback")
+	ErrNoApi       = errors.New("tamtam: context has no Api, create router with Api.NewRouter")
+)
+
+//UpdatePointer is constraint of typed handlers: pointer to update struct, e.g. *schemes.MessageCreatedUpdate. Decoded updates are always pointers, so value types are rejected at compile time
+type UpdatePointer[U any] interface {
+	*U
+	schemes.UpdateInterface
+}
+
+//Context is passed to typed handlers. It carries Api, the update and helpers to reply. Helpers return ErrNoApi if Api is nil, as with routers created by package level NewRouter
+type Context[T schemes.UpdateInterface] struct {
+	context.Context
+	Api         *Api
+	Update      T
+	userSession *Session
+	chatSession *Session
+}
+
+//On adds handler for updates of concrete type T accepted by all matchers, e.g. On[*schemes.MessageCallbackUpdate](router, fn)
+func On[T UpdatePointer[U], U any](r *Router, fn func(c *Context[T]) error, matchers ...Matcher) *Router {
+	handler := func(ctx context.Context, upd schemes.UpdateInterface) error {
+		return fn(&Context[T]{Context: ctx, Api: r.api, Update: upd.(T)})
+	}
+	isType := func(upd schemes.UpdateInterface) bool {
+		_, ok := upd.(T)
+		return ok
+	}
+	return r.Route(handler, append([]Matcher{isType}, matchers...)...)
+}
+
+//ChatID returns chat identifier of update
+func (c *Context[T]) ChatID() int64 {
+	return c.Update.GetChatID()
+}
+
+//UserID returns identifier of user caused update
+func (c *Context[T]) UserID() int64 {
+	return c.Update.GetUserID()
+}
+
+//UserSession returns session of user caused update. It is loaded once per handler call, save changes with Save or Update
+func (c *Context[T]) UserSession() (*Session, error) {
+	if c.userSession != nil {
+		return c.userSession, nil
+	}
+	if c.Api == nil {
+		return nil, ErrNoApi
+	}
+	userID := c.UserID()
+	if userID == 0 {
+		return nil, ErrNoSession
+	}
+	s, err := c.Api.LoadSession(c, UserSessionKey(userID))
+	if err != nil {
+		return nil, err
+	}
+	c.userSession = s
+	return s, nil
+}
+
+//ChatSession returns session of chat where update occurred. It is loaded once per handler call, save changes with Save or Update
+func (c *Context[T]) ChatSession() (*Session, error) {
+	if c.chatSession != nil {
+		return c.chatSession, nil
+	}
+	if c.Api == nil {
+		return nil, ErrNoApi
+	}
+	chatID := c.ChatID()
+	if chatID == 0 {
+		return nil, ErrNoSession
+	}
+	s, err := c.Api.LoadSession(c, ChatSessionKey(chatID))
+	if err != nil {
+		return nil, err
+	}
+	c.chatSession = s
+	return s, nil
+}
+
+//Reply sends text message to chat of update, or to user if update has no chat
+func (c *Context[T]) Reply(text string) error {
+	return c.ReplyMessage(NewMessage().SetText(text))
+}
+
+//ReplyMessage sends message to chat of update, or to user if update has no chat. Recipient set in message is replaced
+func (c *Context[T]) ReplyMessage(m *Message) error {
+	if c.Api == nil {
+		return ErrNoApi
+	}
+	if chatID := c.ChatID(); chatID != 0 {
+		m.SetChat(chatID).SetUser(0)
+	} else {
+		m.SetUser(c.UserID()).SetChat(0)
+	}
+	return c.Api.Messages.SendWithContext(c, m)
+}
+
+//Answer answers button callback. Returns ErrNotCallback for other updates
+func (c *Context[T]) Answer(answer *schemes.CallbackAnswer) error {
+	if c.Api == nil {
+		return ErrNoApi
+	}
+	cb, ok := schemes.UpdateInterface(c.Update).(*schemes.MessageCallbackUpdate)
+	if !ok {
+		return ErrNotCallback
+	}
+	_, err := c.Api.Messages.AnswerOnCallbackWithContext(c, cb.Callback.CallbackID, answer)
+	return err
+}
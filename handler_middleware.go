@@ -0,0 +1,77 @@
+package tamtam
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+//HandlerMiddleware wraps UpdateHandler to add recovery, logging, timeouts, auth checks and so on. Middleware may short-circuit by not calling next
+type HandlerMiddleware func(next UpdateHandler) UpdateHandler
+
+//ChainHandler wraps handler with middlewares. First middleware is the outermost one
+func ChainHandler(h UpdateHandler, mw ...HandlerMiddleware) UpdateHandler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+//Recover converts panic in handler to *PanicError
+func Recover() HandlerMiddleware {
+	return func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, upd schemes.UpdateInterface) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, upd)
+		}
+	}
+}
+
+//Timeout bounds context passed to handler with timeout. Handler is expected to return as soon as context is done
+func Timeout(timeout time.Duration) HandlerMiddleware {
+	return func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, upd schemes.UpdateInterface) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, upd)
+		}
+	}
+}
+
+//LogUpdates logs type, chat and user of every handled update with handling duration. Failed updates are logged as errors
+func LogUpdates(logger Logger) HandlerMiddleware {
+	if logger == nil {
+		logger = DiscardLogger
+	}
+	return func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, upd schemes.UpdateInterface) error {
+			start := time.Now()
+			err := next(ctx, upd)
+			args := []interface{}{"update_type", upd.GetUpdateType(), "chat_id", upd.GetChatID(), "user_id", upd.GetUserID(), "duration", time.Since(start)}
+			if err != nil {
+				logger.Error("update handling failed", append(args, "err", err)...)
+				return err
+			}
+			logger.Info("update handled", args...)
+			return nil
+		}
+	}
+}
+
+//Guard calls handler only for updates allowed by allow. Other updates are skipped without error
+func Guard(allow func(ctx context.Context, upd schemes.UpdateInterface) bool) HandlerMiddleware {
+	return func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, upd schemes.UpdateInterface) error {
+			if !allow(ctx, upd) {
+				return nil
+			}
+			return next(ctx, upd)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package tamtam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+const (
+	defaultJournalMaxSize     = 64 << 20
+	defaultJournalCompactSize = 1 << 20
+	defaultJournalMaxAttempts = 3
+)
+
+//ErrJournalFull returned when updates received at once are larger than journal MaxSize. It is also reported in *JournalDropError for updates evicted from full journal
+var ErrJournalFull = errors.New("tamtam: update journal is full")
+
+//JournalConfig configures Journal. Zero values mean defaults
+type JournalConfig struct {
+	MaxSize     int64 // Maximum size of journal file in bytes. When it is reached, the oldest pending updates are dropped to make room for new ones. Defaults to 64MB
+	CompactSize int64 // Journal file is compacted when it grows over this size and less than half of it is occupied by pending updates. Defaults to 1MB
+	MaxAttempts int   // How many times handler may fail on update, counting replays after restart, before update is dropped. Defaults to 3
+}
+
+//JournalDropError is reported to OnError when update is dropped from journal without being handled
+type JournalDropError struct {
+	Update json.RawMessage // Raw update
+	Err    error           // ErrJournalFull if update was evicted from full journal, otherwise the last handler error
+}
+
+func (e *JournalDropError) Error() string {
+	return "tamtam: update dropped from journal: " + e.Err.Error()
+}
+
+func (e *JournalDropError) Unwrap() error {
+	return e.Err
+}
+
+//Journal is write-ahead log of raw updates. Updates are appended before they are acknowledged (marker is advanced or webhook request is answered) and marked done after handler succeeds. Updates not done before crash or restart are delivered again, so handling is at least once
+type Journal struct {
+	mu       sync.Mutex
+	path     string
+	cfg      JournalConfig
+	file     *os.File
+	size     int64
+	liveSize int64
+	nextID   uint64
+	pending  map[uint64][]byte
+	handed   map[uint64]bool
+	failures map[uint64]int
+	ids      map[schemes.UpdateInterface]uint64 // Keys are pointers to decoded updates, so they are compared by identity
+}
+
+type journalRecord struct {
+	ID       uint64          `json:"id"`
+	Update   json.RawMessage `json:"update,omitempty"`
+	Done     bool            `json:"done,omitempty"`
+	Failures int             `json:"failures,omitempty"`
+}
+
+//WithJournal enables write-ahead journal of updates received by long polling and webhook. Dispatcher marks updates done after successful handling, consumers reading updates channel directly call Journal.Done
+func WithJournal(j *Journal) Option {
+	return func(o *options) {
+		o.journal = j
+	}
+}
+
+//OpenJournal opens journal file at path, creating it if needed, and loads updates that are not done yet. Partially written record at the end of file is discarded, damaged records in the middle are skipped
+func OpenJournal(path string, cfg JournalConfig) (*Journal, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultJournalMaxSize
+	}
+	if cfg.CompactSize <= 0 {
+		cfg.CompactSize = defaultJournalCompactSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultJournalMaxAttempts
+	}
+	j := &Journal{
+		path:     path,
+		cfg:      cfg,
+		nextID:   1,
+		pending:  map[uint64][]byte{},
+		handed:   map[uint64]bool{},
+		failures: map[uint64]int{},
+		ids:      map[schemes.UpdateInterface]uint64{},
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	j.file = f
+	return j, nil
+}
+
+//load reads journal file. Lines that can not be parsed are skipped, incomplete last line is truncated
+func (j *Journal) load() error {
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		rec, raw, ok := parseJournalLine(line)
+		if !ok {
+			continue
+		}
+		if rec.ID >= j.nextID {
+			j.nextID = rec.ID + 1
+		}
+		switch {
+		case rec.Done:
+			j.liveSize -= int64(len(j.pending[rec.ID]))
+			delete(j.pending, rec.ID)
+			delete(j.failures, rec.ID)
+		case rec.Update != nil:
+			j.pending[rec.ID] = raw
+			j.liveSize += int64(len(raw))
+		case rec.Failures > 0:
+			if _, ok := j.pending[rec.ID]; ok {
+				j.failures[rec.ID] = rec.Failures
+			}
+		}
+	}
+	j.size = offset
+	return f.Truncate(offset)
+}
+
+//parseJournalLine parses record of line. Done records are not synced, so after failed write next record may be glued to torn one: then record is looked for after it
+func parseJournalLine(line []byte) (journalRecord, []byte, bool) {
+	start := []byte(`{"id":`)
+	i := 0
+	for {
+		rec := journalRecord{}
+		if err := json.Unmarshal(line[i:], &rec); err == nil {
+			return rec, line[i:], true
+		}
+		next := bytes.Index(line[i+1:], start)
+		if next < 0 {
+			return journalRecord{}, nil, false
+		}
+		i += next + 1
+	}
+}
+
+//Len returns number of updates not done yet
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.pending)
+}
+
+//Done marks update as handled. Updates not received through journal are ignored
+func (j *Journal) Done(upd schemes.UpdateInterface) error {
+	if !trackable(upd) {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.ids[upd]
+	if !ok {
+		return nil
+	}
+	delete(j.ids, upd)
+	return j.done(id)
+}
+
+//Compact rewrites journal file leaving only updates that are not done yet
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.compact()
+}
+
+//Close closes journal file. Pending updates stay in it
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+//append writes raw updates to journal and syncs it to disk. Returns ids of records and updates evicted to make room for them
+func (j *Journal) append(raws ...[]byte) ([]uint64, []json.RawMessage, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	buf := bytes.Buffer{}
+	ids := make([]uint64, 0, len(raws))
+	lines := make([][]byte, 0, len(raws))
+	for i, raw := range raws {
+		id := j.nextID + uint64(i)
+		line, err := json.Marshal(journalRecord{ID: id, Update: raw})
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, '\n')
+		buf.Write(line)
+		ids = append(ids, id)
+		lines = append(lines, line)
+	}
+	n := int64(buf.Len())
+	if n > j.cfg.MaxSize {
+		return nil, nil, ErrJournalFull
+	}
+	var dropped []json.RawMessage
+	if j.size+n > j.cfg.MaxSize {
+		if err := j.compact(); err != nil {
+			return nil, nil, err
+		}
+		if j.size+n > j.cfg.MaxSize {
+			// Pending updates are either failing or not marked done by consumer, so the oldest ones give way to new updates
+			dropped = j.evict(j.size + n - j.cfg.MaxSize)
+			if err := j.compact(); err != nil {
+				return nil, dropped, err
+			}
+			if j.size+n > j.cfg.MaxSize {
+				return nil, dropped, ErrJournalFull
+			}
+		}
+	}
+	if _, err := j.file.Write(buf.Bytes()); err != nil {
+		return nil, dropped, err
+	}
+	if err := j.file.Sync(); err != nil {
+		return nil, dropped, err
+	}
+	j.size += n
+	j.nextID += uint64(len(raws))
+	for i, id := range ids {
+		j.pending[id] = lines[i]
+		j.liveSize += int64(len(lines[i]))
+		j.handed[id] = true
+	}
+	return ids, dropped, nil
+}
+
+//track binds decoded update to its record, so Done can find it. Updates are tracked by pointer identity, record of update that is not pointer is dropped and false is returned
+func (j *Journal) track(id uint64, upd schemes.UpdateInterface) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !trackable(upd) {
+		return false, j.done(id)
+	}
+	j.ids[upd] = id
+	return true, nil
+}
+
+//trackable reports whether update is non-nil pointer that can be used as key of tracked updates
+func trackable(upd schemes.UpdateInterface) bool {
+	v := reflect.ValueOf(upd)
+	return v.Kind() == reflect.Ptr && !v.IsNil()
+}
+
+//journalTrack binds update to journal record logging updates that can not be tracked
+func (a *Api) journalTrack(id uint64, upd schemes.UpdateInterface) {
+	ok, err := a.journal.track(id, upd)
+	if !ok {
+		a.client.logger.Warn("update is not a pointer and can not be journaled", "update_type", upd.GetUpdateType())
+	}
+	if err != nil {
+		a.client.logger.Error("journal update done", "update_type", upd.GetUpdateType(), "err", err)
+	}
+}
+
+//release returns update that was not handed to consumer, so it is replayed again
+func (j *Journal) release(upd schemes.UpdateInterface) {
+	if !trackable(upd) {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if id, ok := j.ids[upd]; ok {
+		delete(j.ids, upd)
+		delete(j.handed, id)
+	}
+}
+
+//fail counts failed handling of update. Update is dropped and returned after MaxAttempts failures, otherwise it stays in journal and is replayed on next start
+func (j *Journal) fail(upd schemes.UpdateInterface) (json.RawMessage, int, error) {
+	if !trackable(upd) {
+		return nil, 0, nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.ids[upd]
+	if !ok {
+		return nil, 0, nil
+	}
+	delete(j.ids, upd)
+	delete(j.handed, id)
+	j.failures[id]++
+	n := j.failures[id]
+	if n >= j.cfg.MaxAttempts {
+		raw := j.update(id)
+		return raw, n, j.done(id)
+	}
+	rec, err := json.Marshal(journalRecord{ID: id, Failures: n})
+	if err != nil {
+		return nil, n, err
+	}
+	rec = append(rec, '\n')
+	// Like done records, failure records are not synced: after crash update just gets one more attempt
+	if _, err := j.file.Write(rec); err != nil {
+		return nil, n, err
+	}
+	j.size += int64(len(rec))
+	return nil, n, nil
+}
+
+//evict removes the oldest pending records occupying at least size bytes and returns their updates. File is shrunk by compaction
+func (j *Journal) evict(size int64) []json.RawMessage {
+	ids := j.sortedPending()
+	evicted := map[uint64]bool{}
+	var dropped []json.RawMessage
+	for _, id := range ids {
+		if size <= 0 {
+			break
+		}
+		size -= int64(len(j.pending[id]))
+		dropped = append(dropped, j.update(id))
+		evicted[id] = true
+		j.liveSize -= int64(len(j.pending[id]))
+		delete(j.pending, id)
+		delete(j.handed, id)
+		delete(j.failures, id)
+	}
+	for upd, id := range j.ids {
+		if evicted[id] {
+			delete(j.ids, upd)
+		}
+	}
+	return dropped
+}
+
+//update returns raw update of pending record
+func (j *Journal) update(id uint64) json.RawMessage {
+	rec := journalRecord{}
+	if err := json.Unmarshal(j.pending[id], &rec); err != nil {
+		return nil
+	}
+	return rec.Update
+}
+
+//sortedPending returns ids of pending records in order they were written
+func (j *Journal) sortedPending() []uint64 {
+	ids := make([]uint64, 0, len(j.pending))
+	for id := range j.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	return ids
+}
+
+//unhand returns records that were taken by replay but not handed to consumer
+func (j *Journal) unhand(ids ...uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, id := range ids {
+		delete(j.handed, id)
+	}
+}
+
+//discard marks record done by id. Used for updates that were written but not acknowledged
+func (j *Journal) discard(id uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done(id)
+}
+
+//replay returns pending updates not handed to consumer yet in order they were written, and marks them handed
+func (j *Journal) replay() ([]uint64, []json.RawMessage) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ids := j.sortedPending()
+	replayed := make([]uint64, 0, len(ids))
+	raws := make([]json.RawMessage, 0, len(ids))
+	for _, id := range ids {
+		if j.handed[id] {
+			continue
+		}
+		raw := j.update(id)
+		if raw == nil {
+			continue
+		}
+		j.handed[id] = true
+		replayed = append(replayed, id)
+		raws = append(raws, raw)
+	}
+	return replayed, raws
+}
+
+func (j *Journal) done(id uint64) error {
+	line, ok := j.pending[id]
+	if !ok {
+		return nil
+	}
+	delete(j.pending, id)
+	delete(j.handed, id)
+	delete(j.failures, id)
+	j.liveSize -= int64(len(line))
+	rec, err := json.Marshal(journalRecord{ID: id, Done: true})
+	if err != nil {
+		return err
+	}
+	rec = append(rec, '\n')
+	// Done records are not synced: after crash update is just delivered once more
+	if _, err := j.file.Write(rec); err != nil {
+		return err
+	}
+	j.size += int64(len(rec))
+	if j.size >= j.cfg.CompactSize && j.liveSize*2 < j.size {
+		return j.compact()
+	}
+	return nil
+}
+
+func (j *Journal) compact() error {
+	ids := j.sortedPending()
+	buf := bytes.Buffer{}
+	for _, id := range ids {
+		buf.Write(j.pending[id])
+	}
+	liveSize := int64(buf.Len())
+	for _, id := range ids {
+		if n := j.failures[id]; n > 0 {
+			rec, err := json.Marshal(journalRecord{ID: id, Failures: n})
+			if err != nil {
+				return err
+			}
+			buf.Write(append(rec, '\n'))
+		}
+	}
+	if err := writeFileAtomic(j.path, buf.Bytes()); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if err := j.file.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	j.file = f
+	j.size = int64(buf.Len())
+	j.liveSize = liveSize
+	return nil
+}
+
+//journalDropped logs updates dropped from journal and reports them to onError, if it is set
+func (a *Api) journalDropped(dropped []json.RawMessage, reason error, onError func(err error)) {
+	for _, raw := range dropped {
+		err := &JournalDropError{Update: raw, Err: reason}
+		a.client.logger.Warn("update dropped from journal", "err", err)
+		if onError != nil {
+			onError(err)
+		}
+	}
+}
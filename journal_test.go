@@ -0,0 +1,208 @@
+package tamtam
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenJournalSkipsDamagedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	lines := []string{
+		`{"id":1,"update":{"update_type":"bot_started","timestamp":1}}`,
+		`garbage`,
+		`{"id":1,"do{"id":2,"update":{"update_type":"bot_started","timestamp":2}}`,
+		`{"id":3,"update":{"update_type":"bot_started","timestamp":3}}`,
+		`{"id":2,"done":true}`,
+		`{"id":4,"update":{"update_type":"bot_started","timestamp":4}}`,
+		`{"id":5,"upd`,
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(path, JournalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	ids, raws := j.replay()
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 3 || ids[2] != 4 {
+		t.Fatalf("expected records 1, 3 and 4 to be pending, got %v", ids)
+	}
+	if want := `{"update_type":"bot_started","timestamp":4}`; string(raws[2]) != want {
+		t.Fatalf("expected %s, got %s", want, raws[2])
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(strings.Join(lines[:6], "\n")) + 1); info.Size() != want {
+		t.Fatalf("expected incomplete last line to be truncated to %d bytes, got %d", want, info.Size())
+	}
+	if _, _, err := j.append([]byte(`{"update_type":"bot_started","timestamp":5}`)); err != nil {
+		t.Fatal(err)
+	}
+	if j.nextID != 6 {
+		t.Fatalf("expected next id 6, got %d", j.nextID)
+	}
+}
+
+func TestJournalEvictsOldestUpdatesWhenFull(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal"), JournalConfig{MaxSize: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	var dropped []json.RawMessage
+	for i := 1; i <= 10; i++ {
+		_, d, err := j.append([]byte(`{"update_type":"bot_started","timestamp":` + strconv.Itoa(i) + `}`))
+		if err != nil {
+			t.Fatalf("update %d: %v", i, err)
+		}
+		dropped = append(dropped, d...)
+	}
+	if len(dropped) == 0 || string(dropped[0]) != `{"update_type":"bot_started","timestamp":1}` {
+		t.Fatalf("expected the oldest update to be dropped first, got %s", dropped)
+	}
+	if j.Len()+len(dropped) != 10 {
+		t.Fatalf("expected every update to be either pending or dropped, got %d pending and %d dropped", j.Len(), len(dropped))
+	}
+	if j.size > j.cfg.MaxSize {
+		t.Fatalf("journal size %d exceeds %d", j.size, j.cfg.MaxSize)
+	}
+	if _, _, err := j.append([]byte(`"` + strings.Repeat("x", 300) + `"`)); err != ErrJournalFull {
+		t.Fatalf("expected ErrJournalFull for update larger than journal, got %v", err)
+	}
+}
+
+func TestJournalDropsUpdateAfterMaxAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	a := New("token")
+	attempt := func(j *Journal) (json.RawMessage, int) {
+		ids, raws := j.replay()
+		if len(ids) != 1 {
+			t.Fatalf("expected one update to replay, got %d", len(ids))
+		}
+		upd, _ := a.DecodeUpdate(raws[0])
+		if ok, err := j.track(ids[0], upd); !ok || err != nil {
+			t.Fatalf("track: %v %v", ok, err)
+		}
+		raw, n, err := j.fail(upd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw, n
+	}
+	j, err := OpenJournal(path, JournalConfig{MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := j.append([]byte(`{"update_type":"bot_started","timestamp":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	j.unhand(1)
+	if raw, n := attempt(j); raw != nil || n != 1 {
+		t.Fatalf("expected update to stay after first failure, got %s after %d attempts", raw, n)
+	}
+	if err := j.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err = OpenJournal(path, JournalConfig{MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if raw, n := attempt(j); raw == nil || n != 2 {
+		t.Fatalf("expected update to be dropped after second failure, got %d attempts", n)
+	}
+	if j.Len() != 0 {
+		t.Fatalf("expected no pending updates, got %d", j.Len())
+	}
+}
+
+func TestWebhookReplayStopsOnShutdown(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal"), JournalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	for i := 1; i <= 3; i++ {
+		if _, _, err := j.append([]byte(`{"update_type":"bot_started","timestamp":` + strconv.Itoa(i) + `}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j.unhand(1, 2, 3)
+	wh := New("token", WithJournal(j)).NewWebhook(WebhookConfig{QueueSize: 1})
+	if err := wh.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); len(wh.updates) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := wh.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown waits for replay: %v", err)
+	}
+	n := 0
+	for range wh.Updates() {
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("expected one replayed update in queue, got %d", n)
+	}
+	if n := j.Len(); n != 3 {
+		t.Fatalf("expected updates to stay in journal until done, got %d", n)
+	}
+}
+
+func TestJournalCompactKeepsPendingUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	a := New("token")
+	j, err := OpenJournal(path, JournalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		raw := []byte(`{"update_type":"bot_started","timestamp":` + strconv.Itoa(i) + `}`)
+		ids, _, err := j.append(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		upd, _ := a.DecodeUpdate(raw)
+		j.track(ids[0], upd)
+		if i%2 == 1 {
+			if err := j.Done(upd); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := j.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 2 {
+		t.Fatalf("expected 2 records after compaction, got %d:\n%s", lines, b)
+	}
+	j, err = OpenJournal(path, JournalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	ids, _ := j.replay()
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Fatalf("expected updates 2 and 4 to be replayed, got %v", ids)
+	}
+}
@@ -0,0 +1,53 @@
+package tamtam
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+//Logger is structured leveled logger. Arguments are alternating keys and values. *slog.Logger satisfies it
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+//DiscardLogger drops all messages
+var DiscardLogger Logger = nopLogger{}
+
+//WithLogger sets logger for client, long polling and webhooks. By default warnings and errors are written with standard log package
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+//stdLogger writes warnings and errors to standard logger
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, args ...interface{}) {}
+func (stdLogger) Info(msg string, args ...interface{})  {}
+func (stdLogger) Warn(msg string, args ...interface{})  { log.Println(formatLog("WARN", msg, args)) }
+func (stdLogger) Error(msg string, args ...interface{}) { log.Println(formatLog("ERROR", msg, args)) }
+
+func formatLog(level, msg string, args []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(level + " " + msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			sb.WriteString(fmt.Sprintf(" %v=%v", args[i], args[i+1]))
+		} else {
+			sb.WriteString(fmt.Sprintf(" !BADKEY=%v", args[i]))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,21 @@
+package tamtam
+
+import (
+	"context"
+	"time"
+)
+
+//longPollMargin is added to poll timeout to give server time to answer
+const longPollMargin = 10 * time.Second
+
+type longPollKey struct{}
+
+//withLongPoll marks ctx as long poll request and bounds it with deadline derived from poll timeout
+func withLongPoll(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithValue(ctx, longPollKey{}, true), timeout+longPollMargin)
+}
+
+func isLongPoll(ctx context.Context) bool {
+	v, _ := ctx.Value(longPollKey{}).(bool)
+	return v
+}
@@ -0,0 +1,105 @@
+package tamtam
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//MarkerStore keeps position of long polling between cycles and restarts
+type MarkerStore interface {
+	//Load returns saved marker or 0 if there is none
+	Load() (int64, error)
+	//Save stores marker of the next updates page
+	Save(marker int64) error
+}
+
+//WithMarkerStore sets store of long polling marker. By default marker is kept in memory
+func WithMarkerStore(store MarkerStore) Option {
+	return func(o *options) {
+		o.markerStore = store
+	}
+}
+
+//MemoryMarkerStore keeps marker in memory. Position is lost on restart
+type MemoryMarkerStore struct {
+	mu     sync.Mutex
+	marker int64
+}
+
+//NewMemoryMarkerStore returns in-memory marker store
+func NewMemoryMarkerStore() *MemoryMarkerStore {
+	return &MemoryMarkerStore{}
+}
+
+//Load returns saved marker
+func (s *MemoryMarkerStore) Load() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marker, nil
+}
+
+//Save stores marker
+func (s *MemoryMarkerStore) Save(marker int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marker = marker
+	return nil
+}
+
+//FileMarkerStore keeps marker in file. File is replaced atomically, so it never contains partially written marker
+type FileMarkerStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+//NewFileMarkerStore returns marker store backed by file at path
+func NewFileMarkerStore(path string) *FileMarkerStore {
+	return &FileMarkerStore{path: path}
+}
+
+//Load reads marker from file. Missing file means no marker
+func (s *FileMarkerStore) Load() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+//Save writes marker to temporary file and renames it over the store file
+func (s *FileMarkerStore) Save(marker int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeFileAtomic(s.path, []byte(strconv.FormatInt(marker, 10)))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,9 +1,10 @@
 package tamtam
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -21,6 +22,11 @@ func newMessages(client *client) *messages {
 
 //GetMessages returns messages in chat: result page and marker referencing to the next page. Messages traversed in reverse direction so the latest message in chat will be first in result array. Therefore if you use from and to parameters, to must be less than from
 func (a *messages) GetMessages(chatID int64, messageIDs []string, from int, to int, count int) (*schemes.MessageList, error) {
+	return a.GetMessagesWithContext(context.Background(), chatID, messageIDs, from, to, count)
+}
+
+//GetMessagesWithContext is GetMessages bound to ctx
+func (a *messages) GetMessagesWithContext(ctx context.Context, chatID int64, messageIDs []string, from int, to int, count int) (*schemes.MessageList, error) {
 	result := new(schemes.MessageList)
 	values := url.Values{}
 	if chatID != 0 {
@@ -40,21 +46,17 @@ func (a *messages) GetMessages(chatID int64, messageIDs []string, from int, to i
 	if count > 0 {
 		values.Set("count", strconv.Itoa(count))
 	}
-	body, err := a.client.request(http.MethodGet, "messages", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Messages.GetMessages", http.MethodGet, "messages", values, nil, result)
 }
 
 //EditMessage updates message by id
 func (a *messages) EditMessage(messageID int64, message *Message) error {
-	s, err := a.editMessage(messageID, message.message)
+	return a.EditMessageWithContext(context.Background(), messageID, message)
+}
+
+//EditMessageWithContext is EditMessage bound to ctx
+func (a *messages) EditMessageWithContext(ctx context.Context, messageID int64, message *Message) error {
+	s, err := a.editMessage(ctx, messageID, message.message)
 	if err != nil {
 		return err
 	}
@@ -66,36 +68,28 @@ func (a *messages) EditMessage(messageID int64, message *Message) error {
 
 //DeleteMessage deletes message by id
 func (a *messages) DeleteMessage(messageID int64) (*schemes.SimpleQueryResult, error) {
+	return a.DeleteMessageWithContext(context.Background(), messageID)
+}
+
+//DeleteMessageWithContext is DeleteMessage bound to ctx
+func (a *messages) DeleteMessageWithContext(ctx context.Context, messageID int64) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
 	values.Set("message_id", strconv.Itoa(int(messageID)))
-	body, err := a.client.request(http.MethodDelete, "messages", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Messages.DeleteMessage", http.MethodDelete, "messages", values, nil, result)
 }
 
 //AnswerOnCallback should be called to send an answer after a user has clicked the button. The answer may be an updated message or/and a one-time user notification.
 func (a *messages) AnswerOnCallback(callbackID string, callback *schemes.CallbackAnswer) (*schemes.SimpleQueryResult, error) {
+	return a.AnswerOnCallbackWithContext(context.Background(), callbackID, callback)
+}
+
+//AnswerOnCallbackWithContext is AnswerOnCallback bound to ctx
+func (a *messages) AnswerOnCallbackWithContext(ctx context.Context, callbackID string, callback *schemes.CallbackAnswer) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
 	values.Set("callback_id", callbackID)
-	body, err := a.client.request(http.MethodPost, "answers", values, callback)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Messages.AnswerOnCallback", http.MethodPost, "answers", values, callback, result)
 }
 
 //NewKeyboardBuilder returns new keyboard builder helper
@@ -107,11 +101,16 @@ func (a *messages) NewKeyboardBuilder() *Keyboard {
 
 //Send sends a message to a chat. As a result for this method new message identifier returns.
 func (a *messages) Send(m *Message) error {
-	return a.sendMessage(m.chatID, m.userID, m.message)
+	return a.SendWithContext(context.Background(), m)
+}
+
+//SendWithContext is Send bound to ctx
+func (a *messages) SendWithContext(ctx context.Context, m *Message) error {
+	return a.sendMessage(ctx, m.chatID, m.userID, m.message)
 }
 
-func (a *messages) sendMessage(chatID int64, userID int64, message *schemes.NewMessageBody) error {
-	result := new(schemes.Error)
+func (a *messages) sendMessage(ctx context.Context, chatID int64, userID int64, message *schemes.NewMessageBody) error {
+	result := new(sendMessageResponse)
 	values := url.Values{}
 	if chatID != 0 {
 		values.Set("chat_id", strconv.Itoa(int(chatID)))
@@ -119,33 +118,39 @@ func (a *messages) sendMessage(chatID int64, userID int64, message *schemes.NewM
 	if userID != 0 {
 		values.Set("user_id", strconv.Itoa(int(userID)))
 	}
-	body, err := a.client.request(http.MethodPost, "messages", values, message)
-	if err != nil {
+	if err := a.client.call(ctx, "Messages.Send", http.MethodPost, "messages", values, message, result); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// Message sent, but answer can not be decoded
+			return nil
+		}
 		return err
 	}
-	defer body.Close()
-	if err := json.NewDecoder(body).Decode(result); err != nil {
-		// Message sent without errors
+	if result.Error.Code == "" {
 		return nil
 	}
-	if result.Code == "" {
-		return nil
+	return newAPIError(http.MethodPost, "messages", http.StatusOK, &result.Error)
+}
+
+//sendMessageResponse is answer of Messages.Send. Successful response may carry error code instead of sent message
+type sendMessageResponse struct {
+	Error  schemes.Error
+	Result schemes.SendMessageResult
+}
+
+func (r *sendMessageResponse) UnmarshalJSON(b []byte) error {
+	// Both answers have "message" field of different types, so they are decoded separately.
+	// Body that can not be decoded still means message has been sent
+	_ = json.Unmarshal(b, &r.Error)
+	if r.Error.Code == "" {
+		_ = json.Unmarshal(b, &r.Result)
 	}
-	return result
+	return nil
 }
 
-func (a *messages) editMessage(messageID int64, message *schemes.NewMessageBody) (*schemes.SimpleQueryResult, error) {
+func (a *messages) editMessage(ctx context.Context, messageID int64, message *schemes.NewMessageBody) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
 	values.Set("message_id", strconv.Itoa(int(messageID)))
-	body, err := a.client.request(http.MethodPut, "messages", values, message)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Messages.EditMessage", http.MethodPut, "messages", values, message, result)
 }
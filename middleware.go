@@ -0,0 +1,36 @@
+package tamtam
+
+import (
+	"context"
+	"net/url"
+)
+
+//Call describes single API call passing through middleware chain
+type Call struct {
+	Name   string      // Logical method name, e.g. "Messages.Send"
+	Method string      // HTTP method
+	Path   string      // Path relative to API endpoint
+	Query  url.Values  // Query parameters. Never contains access token
+	Body   interface{} // Request body before encoding, nil for calls without body
+	Result interface{} // Pointer the response is decoded into. Filled after next handler returns
+}
+
+//CallHandler performs API call
+type CallHandler func(ctx context.Context, call *Call) error
+
+//Middleware wraps CallHandler to add tracing, metrics, debug dumps and so on
+type Middleware func(next CallHandler) CallHandler
+
+//WithMiddleware appends middlewares to the chain. First middleware is the outermost one
+func WithMiddleware(mw ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+func chainMiddleware(h CallHandler, mw []Middleware) CallHandler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
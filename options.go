@@ -0,0 +1,127 @@
+package tamtam
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultURL            = "https://botapi.tamtam.chat/"
+	defaultVersion        = "0.1.8"
+	defaultTimeout        = 30 * time.Second
+	defaultRequestTimeout = 30 * time.Second
+	defaultPause          = time.Second
+)
+
+//Option configures Api created by New
+type Option func(*options)
+
+type options struct {
+	url           *url.URL
+	version       string
+	userAgent     string
+	httpClient    *http.Client
+	transport     http.RoundTripper
+	timeout       time.Duration
+	reqTimeout    time.Duration
+	pause         time.Duration
+	retry         RetryPolicy
+	rateLimit     *RateLimit
+	logger        Logger
+	middleware    []Middleware
+	tokenInHeader bool
+	markerStore   MarkerStore
+	decoders      decoders
+	journal       *Journal
+	sessionStore  SessionStore
+}
+
+func newOptions(opts []Option) *options {
+	u, _ := url.Parse(defaultURL)
+	o := &options{
+		url:        u,
+		version:    defaultVersion,
+		timeout:    defaultTimeout,
+		reqTimeout: defaultRequestTimeout,
+		pause:      defaultPause,
+		logger:     stdLogger{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.markerStore == nil {
+		o.markerStore = NewMemoryMarkerStore()
+	}
+	if o.sessionStore == nil {
+		o.sessionStore = NewMemorySessionStore(defaultSessionTTL)
+	}
+	if o.logger == nil {
+		o.logger = DiscardLogger
+	}
+	if o.httpClient == nil {
+		o.httpClient = &http.Client{Timeout: o.reqTimeout}
+	}
+	if o.transport != nil {
+		cl := *o.httpClient
+		cl.Transport = o.transport
+		o.httpClient = &cl
+	}
+	return o
+}
+
+//WithBaseURL sets Bot API endpoint. Useful for proxies and local stand-in servers
+func WithBaseURL(u *url.URL) Option {
+	return func(o *options) {
+		o.url = u
+	}
+}
+
+//WithHTTPClient sets http client used for API calls and uploads
+func WithHTTPClient(cl *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = cl
+	}
+}
+
+//WithTransport sets transport of http client. Applied on top of WithHTTPClient
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
+}
+
+//WithAPIVersion sets API version sent with every request and subscription
+func WithAPIVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
+
+//WithUserAgent sets User-Agent header of every request
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+//WithTimeout sets long polling timeout. Poll requests do not depend on http client timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+//WithRequestTimeout sets timeout of default http client used for regular API calls. Ignored with WithHTTPClient
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.reqTimeout = timeout
+	}
+}
+
+//WithPause sets pause between long polling cycles
+func WithPause(pause time.Duration) Option {
+	return func(o *options) {
+		o.pause = pause
+	}
+}
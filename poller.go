@@ -0,0 +1,291 @@
+package tamtam
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+const (
+	defaultPollLimit  = 50
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+	dropPendingLimit  = 1000
+)
+
+//PollerConfig configures long polling. Zero values mean defaults
+type PollerConfig struct {
+	Types         []schemes.UpdateType                    // Update types to receive. Empty means all types
+	Limit         int                                     // Maximum number of updates per request. Defaults to 50
+	Timeout       time.Duration                           // Long poll timeout. Defaults to WithTimeout value
+	Pause         time.Duration                           // Pause between poll cycles. Defaults to WithPause value
+	MinBackoff    time.Duration                           // Delay after first failed request. Defaults to Pause, but not less than one second
+	MaxBackoff    time.Duration                           // Upper bound of delay between failed requests. Defaults to one minute
+	DropPending   bool                                    // Skip updates accumulated before start
+	OnError       func(err error)                         // Called on every failed poll request, on every update that could not be decoded and on every update evicted from full journal
+	DrainTimeout  time.Duration                           // How long fetched updates are still offered to consumer after ctx is done. Zero means no draining
+	OnUndelivered func(updates []schemes.UpdateInterface) // Receives updates not delivered on shutdown and moves marker past them. Without it they are fetched again or replayed from journal on next start
+}
+
+func (a *Api) pollerConfig(cfg PollerConfig) PollerConfig {
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultPollLimit
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = a.timeout
+	}
+	if cfg.Pause <= 0 {
+		cfg.Pause = a.pause
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = cfg.Pause
+		if cfg.MinBackoff < defaultMinBackoff {
+			// Zero pause must not turn persistent errors into busy loop
+			cfg.MinBackoff = defaultMinBackoff
+		}
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = defaultMaxBackoff
+		if cfg.MaxBackoff < cfg.MinBackoff {
+			cfg.MaxBackoff = cfg.MinBackoff
+		}
+	}
+	return cfg
+}
+
+//GetUpdatesWithConfig returns updates channel fed by long polling configured with cfg
+func (a *Api) GetUpdatesWithConfig(ctx context.Context, cfg PollerConfig) chan schemes.UpdateInterface {
+	cfg = a.pollerConfig(cfg)
+	ch := make(chan schemes.UpdateInterface)
+	go func() {
+		p := &poller{api: a, cfg: cfg}
+		p.run(ctx, ch)
+		close(ch)
+	}()
+	return ch
+}
+
+type poller struct {
+	api      *Api
+	cfg      PollerConfig
+	marker   int64
+	failures int
+}
+
+func (p *poller) run(ctx context.Context, ch chan schemes.UpdateInterface) {
+	logger := p.api.client.logger
+	marker, err := p.api.markers.Load()
+	if err != nil {
+		logger.Error("load updates marker", "err", err)
+	}
+	p.marker = marker
+	if !p.replay(ctx, ch) {
+		return
+	}
+	if p.cfg.DropPending {
+		if err := p.dropPending(ctx); err != nil {
+			p.fail(err)
+		}
+	}
+	delay := p.cfg.Pause
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+			delay = p.cfg.Pause
+			for {
+				upds, err := p.fetch(ctx, p.cfg.Limit, p.cfg.Timeout)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					delay = p.fail(err)
+					break
+				}
+				p.failures = 0
+				if len(upds.Updates) == 0 {
+					break
+				}
+				ids, err := p.journal(upds)
+				if err != nil {
+					delay = p.fail(err)
+					break
+				}
+				if !p.deliver(ctx, ch, upds, ids) {
+					return
+				}
+				if !p.advance(upds) {
+					break
+				}
+			}
+		}
+	}
+}
+
+//replay delivers updates left in journal by previous run. Returns false if ctx is done before all of them are delivered
+func (p *poller) replay(ctx context.Context, ch chan schemes.UpdateInterface) bool {
+	journal := p.api.journal
+	if journal == nil {
+		return true
+	}
+	ids, raws := journal.replay()
+	if len(raws) == 0 {
+		return true
+	}
+	p.api.client.logger.Info("replaying journaled updates", "count", len(raws))
+	batch := p.decode(raws, ids)
+	for i, upd := range batch {
+		select {
+		case ch <- upd:
+		case <-ctx.Done():
+			for _, upd := range batch[i:] {
+				journal.release(upd)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+//decode decodes raw updates binding them to journal records with ids, if any
+func (p *poller) decode(raws []json.RawMessage, ids []uint64) []schemes.UpdateInterface {
+	logger := p.api.client.logger
+	batch := make([]schemes.UpdateInterface, 0, len(raws))
+	for i, u := range raws {
+		upd, err := p.api.DecodeUpdate(u)
+		if err != nil {
+			logger.Warn("decode update", "err", err)
+			if p.cfg.OnError != nil {
+				p.cfg.OnError(err)
+			}
+		}
+		if ids != nil {
+			p.api.journalTrack(ids[i], upd)
+		}
+		logger.Debug("update received", "update_type", upd.GetUpdateType(), "chat_id", upd.GetChatID(), "user_id", upd.GetUserID())
+		batch = append(batch, upd)
+	}
+	return batch
+}
+
+//deliver sends fetched updates to ch. Returns false if ctx is done before all updates are delivered
+func (p *poller) deliver(ctx context.Context, ch chan schemes.UpdateInterface, upds *schemes.UpdateList, ids []uint64) bool {
+	logger := p.api.client.logger
+	batch := p.decode(upds.Updates, ids)
+	for i, upd := range batch {
+		select {
+		case ch <- upd:
+			continue
+		case <-ctx.Done():
+		}
+		rest := p.drain(ch, batch[i:])
+		if len(rest) == 0 {
+			p.advance(upds)
+			return false
+		}
+		logger.Warn("updates not delivered on shutdown", "count", len(rest))
+		if p.cfg.OnUndelivered != nil {
+			if journal := p.api.journal; journal != nil {
+				// Updates are handed over to callback, so they are not replayed
+				for _, upd := range rest {
+					if err := journal.Done(upd); err != nil {
+						logger.Error("journal update done", "update_type", upd.GetUpdateType(), "err", err)
+					}
+				}
+			}
+			p.advance(upds)
+			p.cfg.OnUndelivered(rest)
+		} else if p.api.journal != nil {
+			// Undelivered updates stay in journal and are replayed on next start
+			for _, upd := range rest {
+				p.api.journal.release(upd)
+			}
+			p.advance(upds)
+		}
+		return false
+	}
+	return true
+}
+
+//drain offers updates to consumer for DrainTimeout and returns ones that were not taken
+func (p *poller) drain(ch chan schemes.UpdateInterface, batch []schemes.UpdateInterface) []schemes.UpdateInterface {
+	if p.cfg.DrainTimeout <= 0 {
+		return batch
+	}
+	timer := time.NewTimer(p.cfg.DrainTimeout)
+	defer timer.Stop()
+	for i, upd := range batch {
+		select {
+		case ch <- upd:
+		case <-timer.C:
+			return batch[i:]
+		}
+	}
+	return nil
+}
+
+//journal writes fetched updates to journal before marker is advanced
+func (p *poller) journal(upds *schemes.UpdateList) ([]uint64, error) {
+	if p.api.journal == nil {
+		return nil, nil
+	}
+	raws := make([][]byte, len(upds.Updates))
+	for i, u := range upds.Updates {
+		raws[i] = u
+	}
+	ids, dropped, err := p.api.journal.append(raws...)
+	p.api.journalDropped(dropped, ErrJournalFull, p.cfg.OnError)
+	return ids, err
+}
+
+func (p *poller) fetch(ctx context.Context, limit int, timeout time.Duration) (*schemes.UpdateList, error) {
+	return p.api.getUpdates(ctx, limit, timeout, p.marker, updateTypeStrings(p.cfg.Types))
+}
+
+//advance moves marker to the next page and saves it. Returns false if server sent no marker
+func (p *poller) advance(upds *schemes.UpdateList) bool {
+	if upds.Marker == nil {
+		return false
+	}
+	p.marker = *upds.Marker
+	if err := p.api.markers.Save(p.marker); err != nil {
+		p.api.client.logger.Error("save updates marker", "marker", p.marker, "err", err)
+	}
+	return true
+}
+
+//dropPending skips backlog without delivering it
+func (p *poller) dropPending(ctx context.Context) error {
+	dropped := 0
+	for {
+		upds, err := p.fetch(ctx, dropPendingLimit, 0)
+		if err != nil {
+			return err
+		}
+		dropped += len(upds.Updates)
+		if len(upds.Updates) == 0 || !p.advance(upds) {
+			p.api.client.logger.Info("pending updates dropped", "count", dropped)
+			return nil
+		}
+	}
+}
+
+//fail reports poll error and returns delay before the next attempt
+func (p *poller) fail(err error) time.Duration {
+	p.failures++
+	delay := p.cfg.MinBackoff
+	for i := 1; i < p.failures && delay < p.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.cfg.MaxBackoff {
+		delay = p.cfg.MaxBackoff
+	}
+	p.api.client.logger.Error("get updates", "marker", p.marker, "attempt", p.failures, "delay", delay, "err", err)
+	if p.cfg.OnError != nil {
+		p.cfg.OnError(err)
+	}
+	return delay
+}
@@ -0,0 +1,187 @@
+package tamtam
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+)
+
+//ErrRateLimited returned by API calls when rate limiter works in fail-fast mode and has no free tokens
+var ErrRateLimited = errors.New("tamtam: rate limit exceeded")
+
+//RateLimit describes client-side limits of outgoing API calls
+type RateLimit struct {
+	Rate         float64       // Global requests per second. Zero means unlimited
+	Burst        int           // Global bucket size
+	PerChatRate  float64       // Requests per second to single chat or user, keyed by chat_id/user_id query values. Zero means unlimited
+	PerChatBurst int           // Per chat bucket size
+	FailFast     bool          // Return ErrRateLimited instead of waiting for free token
+	IdleTimeout  time.Duration // Per chat buckets unused for this time are forgotten. Defaults to one minute
+}
+
+//WithRateLimit enables client-side rate limiting of API calls
+func WithRateLimit(limit RateLimit) Option {
+	return func(o *options) {
+		o.rateLimit = &limit
+	}
+}
+
+type rateLimiter struct {
+	limit     RateLimit
+	global    *bucket
+	mu        sync.Mutex
+	perChat   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.IdleTimeout <= 0 {
+		limit.IdleTimeout = time.Minute
+	}
+	rl := &rateLimiter{limit: limit, perChat: map[string]*bucket{}, lastSweep: time.Now()}
+	if limit.Rate > 0 {
+		rl.global = newBucket(limit.Rate, limit.Burst)
+	}
+	return rl
+}
+
+//wait takes token from bucket of chat or user the request is addressed to and from global bucket
+func (rl *rateLimiter) wait(ctx context.Context, query url.Values) error {
+	chat := rl.chatBucket(query)
+	if chat != nil {
+		if err := rl.take(ctx, chat); err != nil {
+			return err
+		}
+	}
+	if rl.global != nil {
+		if err := rl.take(ctx, rl.global); err != nil {
+			if chat != nil {
+				chat.cancel()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (rl *rateLimiter) take(ctx context.Context, b *bucket) error {
+	if rl.limit.FailFast {
+		if !b.allow(time.Now()) {
+			return ErrRateLimited
+		}
+		return nil
+	}
+	d := b.reserve(time.Now())
+	if d == 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, d); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+func (rl *rateLimiter) chatBucket(query url.Values) *bucket {
+	if rl.limit.PerChatRate <= 0 {
+		return nil
+	}
+	var key string
+	switch {
+	case query.Get("chat_id") != "":
+		key = "c" + query.Get("chat_id")
+	case query.Get("user_id") != "":
+		key = "u" + query.Get("user_id")
+	default:
+		return nil
+	}
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if now.Sub(rl.lastSweep) > rl.limit.IdleTimeout {
+		for k, b := range rl.perChat {
+			if b.idle(now) > rl.limit.IdleTimeout {
+				delete(rl.perChat, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+	b, ok := rl.perChat[key]
+	if !ok {
+		b = newBucket(rl.limit.PerChatRate, rl.limit.PerChatBurst)
+		rl.perChat[key] = b
+	}
+	return b
+}
+
+//bucket is token bucket. Tokens can go negative: it means that callers have reserved future tokens and wait for them
+type bucket struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	lastUsed time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	now := time.Now()
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now, lastUsed: now}
+}
+
+func (b *bucket) advance(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+//allow takes token only if it is available right now
+func (b *bucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.lastUsed = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+//reserve takes token and returns delay until it becomes available
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.lastUsed = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+//cancel returns reserved token back to bucket
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+//idle returns time since bucket was used. Bucket that is not full yet is never idle: forgetting it would allow exceeding the limit
+func (b *bucket) idle(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	if b.tokens < b.burst {
+		return 0
+	}
+	return now.Sub(b.lastUsed)
+}
@@ -0,0 +1,28 @@
+package tamtam
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterEvictsIdleChatBuckets(t *testing.T) {
+	rl := newRateLimiter(RateLimit{PerChatRate: 1000, PerChatBurst: 10, IdleTimeout: 20 * time.Millisecond})
+	for i := 0; i < 101; i++ {
+		if err := rl.wait(context.Background(), url.Values{"chat_id": {strconv.Itoa(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(rl.perChat); n != 101 {
+		t.Fatalf("expected 101 buckets, got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := rl.wait(context.Background(), url.Values{"chat_id": {"new"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rl.perChat); n != 1 {
+		t.Fatalf("expected idle buckets to be evicted, %d left", n)
+	}
+}
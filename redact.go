@@ -0,0 +1,136 @@
+package tamtam
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+//minSecretLen is shortest token replaced in free text. Shorter ones would corrupt unrelated words, so they are redacted in URLs only
+const minSecretLen = 8
+
+//WithTokenInHeader sends access token in Authorization header instead of access_token query parameter. Use it only with API endpoints accepting such header
+func WithTokenInHeader() Option {
+	return func(o *options) {
+		o.tokenInHeader = true
+	}
+}
+
+//redactor removes access token from strings, errors and log records
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(key string) *redactor {
+	r := &redactor{}
+	if len(key) >= minSecretLen {
+		r.secrets = append(r.secrets, key)
+		if escaped := url.QueryEscape(key); escaped != key {
+			r.secrets = append(r.secrets, escaped)
+		}
+	}
+	return r
+}
+
+func (r *redactor) contains(s string) bool {
+	for _, secret := range r.secrets {
+		if strings.Contains(s, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) string(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	return s
+}
+
+//url replaces access_token query parameter and any other occurrence of token in raw URL
+func (r *redactor) url(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		q := u.Query()
+		if q.Get("access_token") != "" {
+			q.Set("access_token", redacted)
+			u.RawQuery = q.Encode()
+			rawURL = u.String()
+		}
+	}
+	return r.string(rawURL)
+}
+
+//error returns err without access token. *url.Error is rebuilt with redacted URL, other errors mentioning token are replaced
+func (r *redactor) error(err error) error {
+	if err == nil {
+		return err
+	}
+	if uerr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: uerr.Op, URL: r.url(uerr.URL), Err: r.error(uerr.Err)}
+	}
+	if !r.contains(err.Error()) {
+		return err
+	}
+	return &redactedError{msg: r.string(err.Error()), err: err}
+}
+
+//redactedError hides original error from Unwrap chain, but still matches it with errors.Is
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Is(target error) bool {
+	return errors.Is(e.err, target)
+}
+
+//redactingLogger removes access token from messages and values before passing them to wrapped logger
+type redactingLogger struct {
+	logger   Logger
+	redactor *redactor
+}
+
+func (l *redactingLogger) Debug(msg string, args ...interface{}) {
+	l.logger.Debug(l.redactor.string(msg), l.args(args)...)
+}
+
+func (l *redactingLogger) Info(msg string, args ...interface{}) {
+	l.logger.Info(l.redactor.string(msg), l.args(args)...)
+}
+
+func (l *redactingLogger) Warn(msg string, args ...interface{}) {
+	l.logger.Warn(l.redactor.string(msg), l.args(args)...)
+}
+
+func (l *redactingLogger) Error(msg string, args ...interface{}) {
+	l.logger.Error(l.redactor.string(msg), l.args(args)...)
+}
+
+func (l *redactingLogger) args(args []interface{}) []interface{} {
+	res := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			res[i] = l.redactor.string(v)
+		case error:
+			res[i] = l.redactor.error(v)
+		case fmt.Stringer:
+			if s := v.String(); l.redactor.contains(s) {
+				res[i] = l.redactor.string(s)
+			} else {
+				res[i] = arg
+			}
+		default:
+			res[i] = arg
+		}
+	}
+	return res
+}
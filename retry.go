@@ -0,0 +1,122 @@
+package tamtam
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//RetryPolicy describes how failed API calls are retried
+type RetryPolicy struct {
+	MaxAttempts        int                // Total number of attempts including the first one. Values below 2 disable retries
+	MinBackoff         time.Duration      // Delay before the first retry
+	MaxBackoff         time.Duration      // Upper bound of delay between attempts, Retry-After from server included. Zero means no bound
+	Jitter             float64            // Fraction of delay (0..1) randomized to spread retries of concurrent callers
+	RetryNonIdempotent bool               // Retry POST and PATCH calls too. Can lead to duplicated messages
+	OnRetry            func(e RetryEvent) // Called before each retry
+}
+
+//RetryEvent describes failed attempt that is about to be retried
+type RetryEvent struct {
+	Method     string
+	Path       string
+	Attempt    int           // Number of failed attempt, starts with 1
+	StatusCode int           // HTTP status of failed attempt, 0 on network errors
+	Err        error         // Error of failed attempt
+	Delay      time.Duration // Delay before next attempt
+}
+
+//DefaultRetryPolicy returns policy with 3 attempts and backoff from 500ms to 10s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+//WithRetry enables retries of failed API calls
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+func (p RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+func (p RetryPolicy) allowsMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return p.RetryNonIdempotent
+}
+
+func (p RetryPolicy) retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+//backoff returns delay after failed attempt (starting with 1). Retry-After from server wins over computed delay, but both are bounded by MaxBackoff
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < math.MaxInt64/2; i++ {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,248 @@
+package tamtam
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+//Matcher reports whether route accepts update
+type Matcher func(upd schemes.UpdateInterface) bool
+
+//MatchType accepts updates of any of types
+func MatchType(types ...schemes.UpdateType) Matcher {
+	return func(upd schemes.UpdateInterface) bool {
+		for _, t := range types {
+			if upd.GetUpdateType() == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//MatchCommand accepts new messages starting with command, e.g. MatchCommand("start") accepts "/start" and "/start payload". Commands addressed to bot as "/start@botname" are not accepted, use MatchCommandFor for them
+func MatchCommand(name string) Matcher {
+	return MatchCommandFor(name, "")
+}
+
+//MatchCommandFor is MatchCommand also accepting command addressed to bot with username, e.g. "/start@username" used in group chats. Commands addressed to other bots are not accepted
+func MatchCommandFor(name, username string) Matcher {
+	name = strings.TrimPrefix(name, "/")
+	return func(upd schemes.UpdateInterface) bool {
+		return matchCommand(messageText(upd), name, username)
+	}
+}
+
+//MatchText accepts new messages with text matching re
+func MatchText(re *regexp.Regexp) Matcher {
+	return func(upd schemes.UpdateInterface) bool {
+		m, ok := upd.(*schemes.MessageCreatedUpdate)
+		return ok && re.MatchString(m.Message.Body.Text)
+	}
+}
+
+//MatchCallback accepts button callbacks with payload starting with prefix
+func MatchCallback(prefix string) Matcher {
+	return func(upd schemes.UpdateInterface) bool {
+		c, ok := upd.(*schemes.MessageCallbackUpdate)
+		return ok && strings.HasPrefix(c.Callback.Payload, prefix)
+	}
+}
+
+//MatchChatType accepts updates from chats of any of types. Updates without known chat type are not accepted
+func MatchChatType(types ...schemes.ChatType) Matcher {
+	return func(upd schemes.UpdateInterface) bool {
+		chatType := updateChatType(upd)
+		for _, t := range types {
+			if chatType == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type route struct {
+	matchers []Matcher
+	handler  UpdateHandler
+}
+
+func (r route) match(upd schemes.UpdateInterface) bool {
+	for _, m := range r.matchers {
+		if !m(upd) {
+			return false
+		}
+	}
+	return true
+}
+
+//Router calls handlers of routes matching update. Routes are tried in order they were added
+type Router struct {
+	api        *Api
+	routes     []route
+	fallback   UpdateHandler
+	matchAll   bool
+	username   string
+	middleware []HandlerMiddleware
+}
+
+//NewRouter returns router calling only the first matching route. Typed handlers added with On get nil Context.Api and its helpers return ErrNoApi, use Api.NewRouter to give them access to Api
+func NewRouter() *Router {
+	return &Router{}
+}
+
+//NewRouter returns router calling only the first matching route. Typed handlers added with On get this Api in their Context
+func (a *Api) NewRouter() *Router {
+	return &Router{api: a}
+}
+
+//MatchAll makes router call every matching route instead of the first one
+func (r *Router) MatchAll() *Router {
+	r.matchAll = true
+	return r
+}
+
+//Username sets bot username, so OnCommand routes also accept commands addressed to bot as /cmd@username
+func (r *Router) Username(username string) *Router {
+	r.username = username
+	return r
+}
+
+//Use appends middlewares wrapping every route handler and fallback. Wrap single handler with ChainHandler to apply middleware to one route only
+func (r *Router) Use(mw ...HandlerMiddleware) *Router {
+	r.middleware = append(r.middleware, mw...)
+	return r
+}
+
+//Route adds handler called for updates accepted by all matchers. Route without matchers accepts any update
+func (r *Router) Route(handler UpdateHandler, matchers ...Matcher) *Router {
+	r.routes = append(r.routes, route{matchers: matchers, handler: handler})
+	return r
+}
+
+//OnType adds handler for updates of type
+func (r *Router) OnType(t schemes.UpdateType, handler UpdateHandler) *Router {
+	return r.Route(handler, MatchType(t))
+}
+
+//OnCommand adds handler for command, e.g. "start" or "/start". Command addressed to bot as /start@username is accepted only if Username is set
+func (r *Router) OnCommand(name string, handler UpdateHandler) *Router {
+	name = strings.TrimPrefix(name, "/")
+	return r.Route(handler, func(upd schemes.UpdateInterface) bool {
+		return matchCommand(messageText(upd), name, r.username)
+	})
+}
+
+//OnText adds handler for new messages with text matching pattern. Pattern must be valid regular expression
+func (r *Router) OnText(pattern string, handler UpdateHandler) *Router {
+	return r.Route(handler, MatchText(regexp.MustCompile(pattern)))
+}
+
+//OnCallback adds handler for button callbacks with payload starting with prefix
+func (r *Router) OnCallback(prefix string, handler UpdateHandler) *Router {
+	return r.Route(handler, MatchCallback(prefix))
+}
+
+//OnChatType adds handler for updates from chats of type
+func (r *Router) OnChatType(t schemes.ChatType, handler UpdateHandler) *Router {
+	return r.Route(handler, MatchChatType(t))
+}
+
+//Fallback sets handler called when no route matches
+func (r *Router) Fallback(handler UpdateHandler) *Router {
+	r.fallback = handler
+	return r
+}
+
+//HandleUpdate calls matching routes. In match all mode every matching route is called and the first error is returned. Pass it to NewDispatcher or call directly
+func (r *Router) HandleUpdate(ctx context.Context, upd schemes.UpdateInterface) error {
+	matched := false
+	var firstErr error
+	for _, rt := range r.routes {
+		if !rt.match(upd) {
+			continue
+		}
+		matched = true
+		err := ChainHandler(rt.handler, r.middleware...)(ctx, upd)
+		if !r.matchAll {
+			return err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if !matched && r.fallback != nil {
+		return ChainHandler(r.fallback, r.middleware...)(ctx, upd)
+	}
+	return firstErr
+}
+
+//messageText returns text of new message or empty string for other updates
+func messageText(upd schemes.UpdateInterface) string {
+	if m, ok := upd.(*schemes.MessageCreatedUpdate); ok {
+		return m.Message.Body.Text
+	}
+	return ""
+}
+
+//matchCommand reports whether text is command name without bot name or addressed to bot with username
+func matchCommand(text, name, username string) bool {
+	cmd, bot, _ := parseCommand(text)
+	if name == "" || cmd != name {
+		return false
+	}
+	return bot == "" || username != "" && strings.EqualFold(bot, username)
+}
+
+//parseCommand splits "/name@bot args" into name without slash, bot name and args. Returns empty name for text that is not a command
+func parseCommand(text string) (string, string, string) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", ""
+	}
+	text = strings.TrimPrefix(text, "/")
+	name, bot, args := text, "", ""
+	if i := strings.IndexAny(text, " \t\n"); i >= 0 {
+		name, args = text[:i], strings.TrimSpace(text[i+1:])
+	}
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, bot = name[:i], name[i+1:]
+	}
+	return name, bot, args
+}
+
+//updateChatType returns type of chat where update occurred or empty string if it is unknown
+func updateChatType(upd schemes.UpdateInterface) schemes.ChatType {
+	switch upd := upd.(type) {
+	case *schemes.MessageCreatedUpdate:
+		return upd.Message.Recipient.ChatType
+	case *schemes.MessageEditedUpdate:
+		return upd.Message.Recipient.ChatType
+	case *schemes.MessageCallbackUpdate:
+		if upd.Message != nil {
+			return upd.Message.Recipient.ChatType
+		}
+	case *schemes.MessageChatCreatedUpdate:
+		return upd.Chat.Type
+	case *schemes.BotStartedUpdate:
+		return schemes.DIALOG
+	case *schemes.BotAddedToChatUpdate:
+		return chatOrChannel(upd.IsChannel)
+	case *schemes.BotRemovedFromChatUpdate:
+		return chatOrChannel(upd.IsChannel)
+	case *schemes.UserAddedToChatUpdate:
+		return chatOrChannel(upd.IsChannel)
+	case *schemes.UserRemovedFromChatUpdate:
+		return chatOrChannel(upd.IsChannel)
+	}
+	return ""
+}
+
+func chatOrChannel(isChannel bool) schemes.ChatType {
+	if isChannel {
+		return schemes.CHANNEL
+	}
+	return schemes.CHAT
+}
@@ -36,6 +36,18 @@ type AttachmentInterface interface {
 	GetAttachmentType() AttachmentType
 }
 
+// Attachment of type unknown to the library. Raw keeps original JSON
+type UnknownAttachment struct {
+	Attachment
+	Raw json.RawMessage `json:"-"`
+}
+
+func NewUnknownAttachment(raw []byte) *UnknownAttachment {
+	a := &UnknownAttachment{Raw: append(json.RawMessage(nil), raw...)}
+	_ = json.Unmarshal(raw, &a.Attachment)
+	return a
+}
+
 type AttachmentPayload struct {
 	// Media attachment URL
 	Url string `json:"url"`
@@ -233,7 +245,17 @@ type Error struct {
 }
 
 func (e Error) Error() string {
-	return e.ErrorText
+	msg := e.ErrorText
+	if msg == "" {
+		msg = e.Message
+	}
+	if e.Code == "" {
+		return msg
+	}
+	if msg == "" {
+		return e.Code
+	}
+	return e.Code + ": " + msg
 }
 
 type FileAttachment struct {
@@ -308,6 +330,50 @@ type Keyboard struct {
 	Buttons [][]ButtonInterface `json:"buttons"`
 }
 
+func (k *Keyboard) UnmarshalJSON(b []byte) error {
+	raw := struct {
+		Buttons [][]json.RawMessage `json:"buttons"`
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	k.Buttons = make([][]ButtonInterface, 0, len(raw.Buttons))
+	for _, rawRow := range raw.Buttons {
+		row := make([]ButtonInterface, 0, len(rawRow))
+		for _, rawButton := range rawRow {
+			button, err := unmarshalButton(rawButton)
+			if err != nil {
+				return err
+			}
+			row = append(row, button)
+		}
+		k.Buttons = append(k.Buttons, row)
+	}
+	return nil
+}
+
+func unmarshalButton(b []byte) (ButtonInterface, error) {
+	button := Button{}
+	if err := json.Unmarshal(b, &button); err != nil {
+		return nil, err
+	}
+	switch button.Type {
+	case LINK:
+		res := LinkButton{}
+		return res, json.Unmarshal(b, &res)
+	case CALLBACK:
+		res := CallbackButton{}
+		return res, json.Unmarshal(b, &res)
+	case CONTACT:
+		res := RequestContactButton{}
+		return res, json.Unmarshal(b, &res)
+	case GEOLOCATION:
+		res := RequestGeoLocationButton{}
+		return res, json.Unmarshal(b, &res)
+	}
+	return button, nil
+}
+
 // After pressing this type of button user follows the link it contains
 type LinkButton struct {
 	Button
@@ -376,6 +442,10 @@ const (
 	TypeUserRemoved      UpdateType = "user_removed"
 	TypeBotStarted       UpdateType = "bot_started"
 	TypeChatTitleChanged UpdateType = "chat_title_changed"
+
+	TypeMessageConstructionRequest UpdateType = "message_construction_request"
+	TypeMessageConstructed         UpdateType = "message_constructed"
+	TypeMessageChatCreated         UpdateType = "message_chat_created"
 )
 
 // MessageLinkType : Type of linked message
@@ -609,7 +679,7 @@ func (u Update) GetUpdateType() UpdateType {
 }
 
 func (u Update) GetUpdateTime() time.Time {
-	return time.Unix(int64(u.Timestamp/1000), 0)
+	return time.Unix(0, int64(u.Timestamp)*int64(time.Millisecond))
 }
 
 type UpdateInterface interface {
@@ -622,8 +692,9 @@ type UpdateInterface interface {
 // You will receive this update when bots has been added to chat
 type BotAddedToChatUpdate struct {
 	Update
-	ChatId int64 `json:"chat_id"` // Chat id where bots was added
-	User   User  `json:"user"`    // User who added bots to chat
+	ChatId    int64 `json:"chat_id"`    // Chat id where bots was added
+	User      User  `json:"user"`       // User who added bots to chat
+	IsChannel bool  `json:"is_channel"` // Indicates whether bots has been added to channel or not
 }
 
 func (b BotAddedToChatUpdate) GetUserID() int64 {
@@ -637,8 +708,9 @@ func (b BotAddedToChatUpdate) GetChatID() int64 {
 // You will receive this update when bots has been removed from chat
 type BotRemovedFromChatUpdate struct {
 	Update
-	ChatId int64 `json:"chat_id"` // Chat identifier bots removed from
-	User   User  `json:"user"`    // User who removed bots from chat
+	ChatId    int64 `json:"chat_id"`    // Chat identifier bots removed from
+	User      User  `json:"user"`       // User who removed bots from chat
+	IsChannel bool  `json:"is_channel"` // Indicates whether bots has been removed from channel or not
 }
 
 func (b BotRemovedFromChatUpdate) GetUserID() int64 {
@@ -652,8 +724,10 @@ func (b BotRemovedFromChatUpdate) GetChatID() int64 {
 // Bot gets this type of update as soon as user pressed `Start` button
 type BotStartedUpdate struct {
 	Update
-	ChatId int64 `json:"chat_id"` // Dialog identifier where event has occurred
-	User   User  `json:"user"`    // User pressed the 'Start' button
+	ChatId     int64  `json:"chat_id"`               // Dialog identifier where event has occurred
+	User       User   `json:"user"`                  // User pressed the 'Start' button
+	Payload    string `json:"payload,omitempty"`     // Additional data from deep-link passed on bot startup
+	UserLocale string `json:"user_locale,omitempty"` // Current user locale in IETF BCP 47 format
 }
 
 func (b BotStartedUpdate) GetUserID() int64 {
@@ -699,8 +773,9 @@ func (b ChatTitleChangedUpdate) GetChatID() int64 {
 // You will get this `update` as soon as user presses button
 type MessageCallbackUpdate struct {
 	Update
-	Callback Callback `json:"callback"`
-	Message  *Message `json:"message"` // Original message containing inline keyboard. Can be `null` in case it had been deleted by the moment a bots got this update
+	Callback   Callback `json:"callback"`
+	Message    *Message `json:"message"`               // Original message containing inline keyboard. Can be `null` in case it had been deleted by the moment a bots got this update
+	UserLocale string   `json:"user_locale,omitempty"` // Current user locale in IETF BCP 47 format
 }
 
 func (b MessageCallbackUpdate) GetUserID() int64 {
@@ -708,13 +783,17 @@ func (b MessageCallbackUpdate) GetUserID() int64 {
 }
 
 func (b MessageCallbackUpdate) GetChatID() int64 {
-	return 0
+	if b.Message == nil {
+		return 0
+	}
+	return b.Message.Recipient.ChatId
 }
 
 // You will get this `update` as soon as message is created
 type MessageCreatedUpdate struct {
 	Update
-	Message Message `json:"message"` // Newly created message
+	Message    Message `json:"message"`               // Newly created message
+	UserLocale string  `json:"user_locale,omitempty"` // Current user locale in IETF BCP 47 format. Available only in dialogs
 }
 
 func (b MessageCreatedUpdate) GetUserID() int64 {
@@ -743,14 +822,16 @@ func (b MessageEditedUpdate) GetChatID() int64 {
 type MessageRemovedUpdate struct {
 	Update
 	MessageId string `json:"message_id"` // Identifier of removed message
+	ChatId    int64  `json:"chat_id"`    // Chat identifier where message has been deleted
+	UserId    int64  `json:"user_id"`    // User who deleted this message
 }
 
 func (b MessageRemovedUpdate) GetUserID() int64 {
-	return 0
+	return b.UserId
 }
 
 func (b MessageRemovedUpdate) GetChatID() int64 {
-	return 0
+	return b.ChatId
 }
 
 // You will receive this update when user has been added to chat where bots is administrator
@@ -758,7 +839,8 @@ type UserAddedToChatUpdate struct {
 	Update
 	ChatId    int64 `json:"chat_id"`    // Chat identifier where event has occurred
 	User      User  `json:"user"`       // User added to chat
-	InviterId int64 `json:"inviter_id"` // User who added user to chat
+	InviterId int64 `json:"inviter_id"` // User who added user to chat. Can be `null` in case when user joined chat by link
+	IsChannel bool  `json:"is_channel"` // Indicates whether user has been added to channel or not
 }
 
 func (b UserAddedToChatUpdate) GetUserID() int64 {
@@ -772,9 +854,10 @@ func (b UserAddedToChatUpdate) GetChatID() int64 {
 // You will receive this update when user has been removed from chat where bots is administrator
 type UserRemovedFromChatUpdate struct {
 	Update
-	ChatId  int64 `json:"chat_id"`  // Chat identifier where event has occurred
-	User    User  `json:"user"`     // User removed from chat
-	AdminId int64 `json:"admin_id"` // Administrator who removed user from chat
+	ChatId    int64 `json:"chat_id"`    // Chat identifier where event has occurred
+	User      User  `json:"user"`       // User removed from chat
+	AdminId   int64 `json:"admin_id"`   // Administrator who removed user from chat. Can be `null` in case when user left chat
+	IsChannel bool  `json:"is_channel"` // Indicates whether user has been removed from channel or not
 }
 
 func (b UserRemovedFromChatUpdate) GetUserID() int64 {
@@ -784,3 +867,101 @@ func (b UserRemovedFromChatUpdate) GetUserID() int64 {
 func (b UserRemovedFromChatUpdate) GetChatID() int64 {
 	return b.ChatId
 }
+
+// Bot will get this update when user sent to bot any message or pressed button during construction process
+type MessageConstructionRequestUpdate struct {
+	Update
+	User       UserWithPhoto    `json:"user"`                  // User who requested construction
+	UserLocale string           `json:"user_locale,omitempty"` // Current user locale in IETF BCP 47 format
+	SessionId  string           `json:"session_id"`            // Constructor session identifier
+	Data       string           `json:"data,omitempty"`        // Data previously saved by bot in this session
+	Input      ConstructorInput `json:"input"`                 // User input. It can be message (text/attachments) or simple button's callback
+}
+
+func (b MessageConstructionRequestUpdate) GetUserID() int64 {
+	return b.User.UserId
+}
+
+func (b MessageConstructionRequestUpdate) GetChatID() int64 {
+	return 0
+}
+
+// ConstructorInputType : Type of user input during message construction
+type ConstructorInputType string
+
+// List of ConstructorInputType
+const (
+	ConstructorInputMessage  ConstructorInputType = "message"
+	ConstructorInputCallback ConstructorInputType = "callback"
+)
+
+// It is user input in constructor: messages he sent or button he pressed
+type ConstructorInput struct {
+	Type     ConstructorInputType `json:"type"`
+	Messages []NewMessageBody     `json:"messages,omitempty"` // All user messages, for `message` input
+	Payload  string               `json:"payload,omitempty"`  // Button payload, for `callback` input
+}
+
+// Bot will get this update when constructed message has been posted to any chat
+type MessageConstructedUpdate struct {
+	Update
+	SessionId string             `json:"session_id"` // Constructor session identifier
+	Message   ConstructedMessage `json:"message"`    // Constructed message
+}
+
+func (b MessageConstructedUpdate) GetUserID() int64 {
+	return b.Message.Sender.UserId
+}
+
+func (b MessageConstructedUpdate) GetChatID() int64 {
+	return 0
+}
+
+// Message constructed by bot on behalf of user
+type ConstructedMessage struct {
+	Sender    User           `json:"sender"`         // User who constructed this message
+	Timestamp int64          `json:"timestamp"`      // Unix-time when message was created
+	Link      *LinkedMessage `json:"link,omitempty"` // Forwarder or replied message
+	Body      MessageBody    `json:"body"`           // Body of created message. Text + attachments
+}
+
+// Bot will get this update when chat has been created as soon as first user clicked chat button
+type MessageChatCreatedUpdate struct {
+	Update
+	Chat         Chat   `json:"chat"`                    // Created chat
+	MessageId    string `json:"message_id"`              // Message identifier where the button has been clicked
+	StartPayload string `json:"start_payload,omitempty"` // Payload from chat button
+}
+
+func (b MessageChatCreatedUpdate) GetUserID() int64 {
+	return b.Chat.OwnerId
+}
+
+func (b MessageChatCreatedUpdate) GetChatID() int64 {
+	return b.Chat.ChatId
+}
+
+// Update of type unknown to the library. Raw keeps original JSON
+type UnknownUpdate struct {
+	Update
+	ChatId int64           `json:"chat_id,omitempty"` // Chat identifier, if update has one
+	User   *User           `json:"user,omitempty"`    // User, if update has one
+	Raw    json.RawMessage `json:"-"`
+}
+
+func NewUnknownUpdate(raw []byte) *UnknownUpdate {
+	u := &UnknownUpdate{Raw: append(json.RawMessage(nil), raw...)}
+	_ = json.Unmarshal(raw, u)
+	return u
+}
+
+func (b UnknownUpdate) GetUserID() int64 {
+	if b.User == nil {
+		return 0
+	}
+	return b.User.UserId
+}
+
+func (b UnknownUpdate) GetChatID() int64 {
+	return b.ChatId
+}
@@ -0,0 +1,307 @@
+package tamtam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionTTL  = 24 * time.Hour
+	sessionUpdateTries = 3
+)
+
+var (
+	ErrSessionConflict = errors.New("tamtam: session was changed concurrently")
+	ErrNoSession       = errors.New("tamtam: update has no user or chat to keep session for")
+)
+
+//SessionStore keeps sessions data. Save must implement optimistic locking: data is stored only if session version has not changed since it was loaded
+type SessionStore interface {
+	//Load returns session data and version. Missing session has nil data and version 0
+	Load(ctx context.Context, key string) ([]byte, int64, error)
+	//Save stores data if current session version equals version and returns new version. Otherwise it returns ErrSessionConflict
+	Save(ctx context.Context, key string, data []byte, version int64) (int64, error)
+	//Delete removes session
+	Delete(ctx context.Context, key string) error
+}
+
+//WithSessionStore sets store of handler sessions. By default sessions are kept in memory for 24 hours after the last save
+func WithSessionStore(store SessionStore) Option {
+	return func(o *options) {
+		o.sessionStore = store
+	}
+}
+
+//UserSessionKey returns key of user session
+func UserSessionKey(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+//ChatSessionKey returns key of chat session
+func ChatSessionKey(chatID int64) string {
+	return "chat:" + strconv.FormatInt(chatID, 10)
+}
+
+//Session holds named values kept between updates. Changes are stored by Save
+type Session struct {
+	store   SessionStore
+	key     string
+	version int64
+	values  map[string]json.RawMessage
+}
+
+//LoadSession loads session by key. Missing session is empty
+func (a *Api) LoadSession(ctx context.Context, key string) (*Session, error) {
+	s := &Session{store: a.sessions, key: key}
+	return s, s.Reload(ctx)
+}
+
+//DeleteSession removes session by key, e.g. UserSessionKey(userID) on user request to forget their data
+func (a *Api) DeleteSession(ctx context.Context, key string) error {
+	return a.sessions.Delete(ctx, key)
+}
+
+//Key returns session key
+func (s *Session) Key() string {
+	return s.key
+}
+
+//Get decodes value into v. Returns false if there is no such value
+func (s *Session) Get(name string, v interface{}) (bool, error) {
+	raw, ok := s.values[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+//Set sets value. It must be encodable to JSON
+func (s *Session) Set(name string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.values[name] = raw
+	return nil
+}
+
+//Unset removes value
+func (s *Session) Unset(name string) {
+	delete(s.values, name)
+}
+
+//Save stores session. Returns ErrSessionConflict if session was saved by someone else since it was loaded
+func (s *Session) Save(ctx context.Context) error {
+	data, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	version, err := s.store.Save(ctx, s.key, data, s.version)
+	if err != nil {
+		return err
+	}
+	s.version = version
+	return nil
+}
+
+//Reload discards unsaved changes and loads the latest session state
+func (s *Session) Reload(ctx context.Context) error {
+	data, version, err := s.store.Load(ctx, s.key)
+	if err != nil {
+		return err
+	}
+	s.values = map[string]json.RawMessage{}
+	s.version = version
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &s.values)
+}
+
+//Delete removes session from store and clears its values
+func (s *Session) Delete(ctx context.Context) error {
+	if err := s.store.Delete(ctx, s.key); err != nil {
+		return err
+	}
+	s.values = map[string]json.RawMessage{}
+	s.version = 0
+	return nil
+}
+
+//Update applies fn to session and saves it. On conflict session is reloaded and fn is applied again
+func (s *Session) Update(ctx context.Context, fn func(s *Session) error) error {
+	for try := 1; ; try++ {
+		if err := fn(s); err != nil {
+			return err
+		}
+		err := s.Save(ctx)
+		if err != ErrSessionConflict || try >= sessionUpdateTries {
+			return err
+		}
+		if err := s.Reload(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+type sessionRecord struct {
+	Version int64           `json:"version"`
+	Data    json.RawMessage `json:"data"`
+	expires time.Time
+}
+
+//MemorySessionStore keeps sessions in memory. Sessions not saved for TTL are evicted
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	sessions  map[string]*sessionRecord
+	lastSweep time.Time
+}
+
+//NewMemorySessionStore returns in-memory store evicting sessions after ttl. Zero ttl means sessions are never evicted
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{ttl: ttl, sessions: map[string]*sessionRecord{}, lastSweep: time.Now()}
+}
+
+//Load returns session data and version
+func (s *MemorySessionStore) Load(ctx context.Context, key string) ([]byte, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.get(key)
+	if rec == nil {
+		return nil, 0, nil
+	}
+	return rec.Data, rec.Version, nil
+}
+
+//Save stores data if version matches
+func (s *MemorySessionStore) Save(ctx context.Context, key string, data []byte, version int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current int64
+	if rec := s.get(key); rec != nil {
+		current = rec.Version
+	}
+	if current != version {
+		return 0, ErrSessionConflict
+	}
+	rec := &sessionRecord{Version: version + 1, Data: data}
+	if s.ttl > 0 {
+		rec.expires = time.Now().Add(s.ttl)
+	}
+	s.sessions[key] = rec
+	return rec.Version, nil
+}
+
+//Delete removes session
+func (s *MemorySessionStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+//get returns live session record sweeping expired ones from time to time
+func (s *MemorySessionStore) get(key string) *sessionRecord {
+	if s.ttl <= 0 {
+		return s.sessions[key]
+	}
+	now := time.Now()
+	if now.Sub(s.lastSweep) > s.ttl {
+		for k, rec := range s.sessions {
+			if now.After(rec.expires) {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	rec, ok := s.sessions[key]
+	if !ok || now.After(rec.expires) {
+		return nil
+	}
+	return rec
+}
+
+//FileSessionStore keeps every session in JSON file in directory. Files are replaced atomically. Locking works within single process
+type FileSessionStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+//NewFileSessionStore returns store keeping sessions in dir. Directory is created if needed
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+//Load reads session file. Missing file means no session
+func (s *FileSessionStore) Load(ctx context.Context, key string) ([]byte, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.read(key)
+	if err != nil || rec == nil {
+		return nil, 0, err
+	}
+	return rec.Data, rec.Version, nil
+}
+
+//Save writes session file if version matches
+func (s *FileSessionStore) Save(ctx context.Context, key string, data []byte, version int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.read(key)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	if rec != nil {
+		current = rec.Version
+	}
+	if current != version {
+		return 0, ErrSessionConflict
+	}
+	b, err := json.Marshal(sessionRecord{Version: version + 1, Data: data})
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(s.path(key), b); err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+//Delete removes session file
+func (s *FileSessionStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileSessionStore) read(key string) (*sessionRecord, error) {
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := new(sessionRecord)
+	return rec, json.Unmarshal(b, rec)
+}
+
+func (s *FileSessionStore) path(key string) string {
+	return filepath.Join(s.dir, url.QueryEscape(key)+".json")
+}
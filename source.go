@@ -0,0 +1,230 @@
+package tamtam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+//UpdateSource delivers updates regardless of transport: long polling, webhook or fixtures in tests
+type UpdateSource interface {
+	//Start begins receiving updates
+	Start(ctx context.Context) error
+	//Updates returns channel with updates. It is closed when source stops
+	Updates() <-chan schemes.UpdateInterface
+	//Stop stops receiving updates
+	Stop(ctx context.Context) error
+}
+
+var (
+	_ UpdateSource = (*LongPollSource)(nil)
+	_ UpdateSource = (*Webhook)(nil)
+	_ UpdateSource = (*SubscriptionManager)(nil)
+	_ UpdateSource = (*MemorySource)(nil)
+	_ UpdateSource = (*ReplaySource)(nil)
+)
+
+var errSourceStarted = errors.New("tamtam: update source already started")
+
+//LongPollSource receives updates with long polling
+type LongPollSource struct {
+	api     *Api
+	cfg     PollerConfig
+	mu      sync.Mutex
+	updates <-chan schemes.UpdateInterface
+	cancel  context.CancelFunc
+}
+
+//NewLongPollSource returns update source polling with cfg
+func (a *Api) NewLongPollSource(cfg PollerConfig) *LongPollSource {
+	return &LongPollSource{api: a, cfg: cfg}
+}
+
+//Start starts polling bound to ctx
+func (s *LongPollSource) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.updates != nil {
+		return errSourceStarted
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.updates = s.api.GetUpdatesWithConfig(ctx, s.cfg)
+	return nil
+}
+
+//Updates returns channel with updates. Available after Start
+func (s *LongPollSource) Updates() <-chan schemes.UpdateInterface {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updates
+}
+
+//Stop cancels polling. Updates channel is closed as soon as poller exits
+func (s *LongPollSource) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+//Start puts updates left in journal by previous run into queue, if journal is enabled. Otherwise it does nothing: webhook receives updates as soon as it is served
+func (wh *Webhook) Start(ctx context.Context) error {
+	if wh.api.journal == nil {
+		return nil
+	}
+	ids, raws := wh.api.journal.replay()
+	if len(raws) > 0 {
+		wh.api.client.logger.Info("replaying journaled updates", "count", len(raws))
+		go wh.replay(ctx, ids, raws)
+	}
+	return nil
+}
+
+//Stop is Shutdown
+func (wh *Webhook) Stop(ctx context.Context) error {
+	return wh.Shutdown(ctx)
+}
+
+//MemorySource delivers updates pushed by hand. Useful in tests and for replaying fixtures
+type MemorySource struct {
+	updates chan schemes.UpdateInterface
+	once    sync.Once
+}
+
+//NewMemorySource returns in-memory source with buffer of given size
+func NewMemorySource(buffer int) *MemorySource {
+	return &MemorySource{updates: make(chan schemes.UpdateInterface, buffer)}
+}
+
+//Push delivers update to consumer. It blocks while buffer is full
+func (s *MemorySource) Push(ctx context.Context, upd schemes.UpdateInterface) error {
+	select {
+	case s.updates <- upd:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//Start does nothing
+func (s *MemorySource) Start(ctx context.Context) error {
+	return nil
+}
+
+//Updates returns channel with pushed updates
+func (s *MemorySource) Updates() <-chan schemes.UpdateInterface {
+	return s.updates
+}
+
+//Stop closes updates channel. Push must not be called after Stop
+func (s *MemorySource) Stop(ctx context.Context) error {
+	s.once.Do(func() {
+		close(s.updates)
+	})
+	return nil
+}
+
+//NewReplaySource returns source replaying updates in JSON read from r: one update per value or arrays of updates, as in UpdateList.Updates.
+//Source stops itself when r is exhausted or update cannot be decoded, see Err
+func (a *Api) NewReplaySource(r io.Reader) *ReplaySource {
+	return &ReplaySource{api: a, r: r, mem: NewMemorySource(0)}
+}
+
+//ReplaySource delivers updates decoded from JSON stream
+type ReplaySource struct {
+	api     *Api
+	r       io.Reader
+	mem     *MemorySource
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	err     error
+	done    chan struct{}
+}
+
+//Start begins replaying in background
+func (s *ReplaySource) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errSourceStarted
+	}
+	s.started = true
+	s.done = make(chan struct{})
+	ctx, s.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(s.done)
+		err := s.replay(ctx)
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		_ = s.mem.Stop(ctx)
+	}()
+	return nil
+}
+
+func (s *ReplaySource) replay(ctx context.Context) error {
+	dec := json.NewDecoder(s.r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		batch := []json.RawMessage{raw}
+		if len(raw) > 0 && raw[0] == '[' {
+			batch = nil
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return err
+			}
+		}
+		for _, b := range batch {
+			upd, err := s.api.DecodeUpdate(b)
+			if err != nil {
+				return err
+			}
+			if err := s.mem.Push(ctx, upd); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//Updates returns channel with replayed updates
+func (s *ReplaySource) Updates() <-chan schemes.UpdateInterface {
+	return s.mem.Updates()
+}
+
+//Stop interrupts replay and waits for it to finish
+func (s *ReplaySource) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.Err()
+}
+
+//Err returns error that interrupted replay, if any
+func (s *ReplaySource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == context.Canceled {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,174 @@
+package tamtam
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+//SubscriptionConfig describes how bot receives updates
+type SubscriptionConfig struct {
+	PublicURL   string               // Public URL webhook is reachable at. Path and secret from Webhook are appended. Empty means long polling
+	Webhook     WebhookConfig        // Webhook server settings
+	UpdateTypes []schemes.UpdateType // Update types to receive. Empty means all types
+	Version     string               // API version of subscription. Defaults to WithAPIVersion value
+	KeepOthers  bool                 // Keep subscriptions with other URLs, e.g. of other deployments. By default they are removed
+	Poller      PollerConfig         // Long polling settings used when PublicURL is empty. UpdateTypes override Poller.Types
+}
+
+//SubscriptionManager reconciles webhook subscriptions with config and delivers updates either from webhook or from long polling
+type SubscriptionManager struct {
+	api     *Api
+	cfg     SubscriptionConfig
+	mu      sync.Mutex
+	webhook *Webhook
+	url     string
+	updates <-chan schemes.UpdateInterface
+	cancel  context.CancelFunc
+}
+
+//NewSubscriptionManager returns manager driven by cfg. Call Start to begin receiving updates
+func (a *Api) NewSubscriptionManager(cfg SubscriptionConfig) *SubscriptionManager {
+	if cfg.Version == "" {
+		cfg.Version = a.client.version
+	}
+	return &SubscriptionManager{api: a, cfg: cfg}
+}
+
+//Start brings subscriptions to desired state. With PublicURL it subscribes webhook unless identical subscription exists,
+//otherwise it removes webhook subscriptions (they block long polling) and starts polling bound to ctx
+func (m *SubscriptionManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.updates != nil {
+		return errSourceStarted
+	}
+	if m.cfg.PublicURL == "" {
+		if err := m.reconcile(ctx, ""); err != nil {
+			return err
+		}
+		pollCtx, cancel := context.WithCancel(ctx)
+		poller := m.cfg.Poller
+		if len(m.cfg.UpdateTypes) > 0 {
+			poller.Types = m.cfg.UpdateTypes
+		}
+		m.cancel = cancel
+		m.updates = m.api.GetUpdatesWithConfig(pollCtx, poller)
+		m.api.client.logger.Info("receiving updates with long polling")
+		return nil
+	}
+	webhook := m.api.NewWebhook(m.cfg.Webhook)
+	url := webhook.SubscriptionURL(m.cfg.PublicURL)
+	if err := m.reconcile(ctx, url); err != nil {
+		return err
+	}
+	if err := webhook.Start(ctx); err != nil {
+		return err
+	}
+	m.webhook = webhook
+	m.url = url
+	m.updates = webhook.Updates()
+	m.api.client.logger.Info("receiving updates with webhook", "path", m.cfg.Webhook.Path)
+	return nil
+}
+
+//reconcile removes subscriptions with other URLs and subscribes desired URL if it is missing or has other types or version. Empty URL means no subscriptions at all
+func (m *SubscriptionManager) reconcile(ctx context.Context, url string) error {
+	subs, err := m.api.Subscriptions.GetSubscriptionsWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	subscribed := false
+	for _, s := range subs.Subscriptions {
+		if url != "" && s.Url == url {
+			// Subscribing the same URL again replaces its types and version, so it is not removed first and webhook keeps receiving updates
+			subscribed = m.matches(s)
+			continue
+		}
+		if m.cfg.KeepOthers && url != "" {
+			continue
+		}
+		if _, err := m.api.Subscriptions.UnsubscribeWithContext(ctx, s.Url); err != nil {
+			return err
+		}
+	}
+	if url == "" || subscribed {
+		return nil
+	}
+	res, err := m.api.Subscriptions.subscribe(ctx, &schemes.SubscriptionRequestBody{
+		Url:         url,
+		UpdateTypes: updateTypeStrings(m.cfg.UpdateTypes),
+		Version:     m.cfg.Version,
+	})
+	if err != nil {
+		return err
+	}
+	if !res.Success {
+		return errors.New("tamtam: subscribe: " + res.Message)
+	}
+	return nil
+}
+
+//matches reports whether existing subscription has desired update types and version
+func (m *SubscriptionManager) matches(s schemes.Subscription) bool {
+	if s.Version != "" && s.Version != m.cfg.Version {
+		return false
+	}
+	want := updateTypeStrings(m.cfg.UpdateTypes)
+	if len(want) != len(s.UpdateTypes) {
+		return false
+	}
+	have := append([]string(nil), s.UpdateTypes...)
+	sort.Strings(want)
+	sort.Strings(have)
+	for i := range want {
+		if want[i] != have[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//Updates returns channel with updates. Available after Start
+func (m *SubscriptionManager) Updates() <-chan schemes.UpdateInterface {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updates
+}
+
+//Webhook returns webhook server to mount into http server. Nil when long polling is used
+func (m *SubscriptionManager) Webhook() *Webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.webhook
+}
+
+//Stop unsubscribes webhook and closes updates channel, or stops long polling
+func (m *SubscriptionManager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+		return nil
+	}
+	if m.webhook == nil {
+		return nil
+	}
+	_, err := m.api.Subscriptions.UnsubscribeWithContext(ctx, m.url)
+	if shutdownErr := m.webhook.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+func updateTypeStrings(types []schemes.UpdateType) []string {
+	res := make([]string, 0, len(types))
+	for _, t := range types {
+		res = append(res, string(t))
+	}
+	return res
+}
@@ -1,8 +1,7 @@
 package tamtam
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"net/http"
 	"net/url"
 
@@ -19,54 +18,45 @@ func newSubscriptions(client *client) *subscriptions {
 
 //GetSubscriptions returns list of all subscriptions
 func (a *subscriptions) GetSubscriptions() (*schemes.GetSubscriptionsResult, error) {
+	return a.GetSubscriptionsWithContext(context.Background())
+}
+
+//GetSubscriptionsWithContext is GetSubscriptions bound to ctx
+func (a *subscriptions) GetSubscriptionsWithContext(ctx context.Context) (*schemes.GetSubscriptionsResult, error) {
 	result := new(schemes.GetSubscriptionsResult)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodGet, "subscriptions", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Subscriptions.GetSubscriptions", http.MethodGet, "subscriptions", values, nil, result)
 }
 
 //Subscribe subscribes bot to receive updates via WebHook
 func (a *subscriptions) Subscribe(subscribeURL string, updateTypes []string) (*schemes.SimpleQueryResult, error) {
-	subscription := &schemes.SubscriptionRequestBody{
+	return a.SubscribeWithContext(context.Background(), subscribeURL, updateTypes)
+}
+
+//SubscribeWithContext is Subscribe bound to ctx
+func (a *subscriptions) SubscribeWithContext(ctx context.Context, subscribeURL string, updateTypes []string) (*schemes.SimpleQueryResult, error) {
+	return a.subscribe(ctx, &schemes.SubscriptionRequestBody{
 		Url:         subscribeURL,
 		UpdateTypes: updateTypes,
 		Version:     a.client.version,
-	}
+	})
+}
+
+func (a *subscriptions) subscribe(ctx context.Context, subscription *schemes.SubscriptionRequestBody) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
-	body, err := a.client.request(http.MethodPost, "subscriptions", values, subscription)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Subscriptions.Subscribe", http.MethodPost, "subscriptions", values, subscription, result)
 }
 
 //Unsubscribe unsubscribes bot from receiving updates via WebHook
 func (a *subscriptions) Unsubscribe(subscriptionURL string) (*schemes.SimpleQueryResult, error) {
+	return a.UnsubscribeWithContext(context.Background(), subscriptionURL)
+}
+
+//UnsubscribeWithContext is Unsubscribe bound to ctx
+func (a *subscriptions) UnsubscribeWithContext(ctx context.Context, subscriptionURL string) (*schemes.SimpleQueryResult, error) {
 	result := new(schemes.SimpleQueryResult)
 	values := url.Values{}
 	values.Set("url", subscriptionURL)
-	body, err := a.client.request(http.MethodDelete, "subscriptions", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Subscriptions.Unsubscribe", http.MethodDelete, "subscriptions", values, nil, result)
 }
@@ -2,9 +2,9 @@ package tamtam
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -23,75 +23,106 @@ func newUploads(client *client) *uploads {
 
 //UploadMedia uploads file to TamTam server
 func (a *uploads) UploadMediaFromFile(uploadType schemes.UploadType, filename string) (*schemes.UploadedInfo, error) {
+	return a.UploadMediaFromFileWithContext(context.Background(), uploadType, filename)
+}
+
+//UploadMediaFromFileWithContext is UploadMediaFromFile bound to ctx
+func (a *uploads) UploadMediaFromFileWithContext(ctx context.Context, uploadType schemes.UploadType, filename string) (*schemes.UploadedInfo, error) {
 	fh, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer fh.Close()
-	return a.UploadMediaFromReader(uploadType, fh)
+	return a.UploadMediaFromReaderWithContext(ctx, uploadType, fh)
 }
 
 //UploadMediaFromUrl uploads file from remote server to TamTam server
 func (a *uploads) UploadMediaFromUrl(uploadType schemes.UploadType, u url.URL) (*schemes.UploadedInfo, error) {
-	respFile, err := http.Get(u.String())
+	return a.UploadMediaFromUrlWithContext(context.Background(), uploadType, u)
+}
+
+//UploadMediaFromUrlWithContext is UploadMediaFromUrl bound to ctx
+func (a *uploads) UploadMediaFromUrlWithContext(ctx context.Context, uploadType schemes.UploadType, u url.URL) (*schemes.UploadedInfo, error) {
+	respFile, err := a.download(ctx, u)
 	if err != nil {
 		return nil, err
 	}
 	defer respFile.Body.Close()
-	return a.UploadMediaFromReader(uploadType, respFile.Body)
+	return a.UploadMediaFromReaderWithContext(ctx, uploadType, respFile.Body)
 }
 
+//UploadMediaFromReader uploads file from reader
 func (a *uploads) UploadMediaFromReader(uploadType schemes.UploadType, reader io.Reader) (*schemes.UploadedInfo, error) {
+	return a.UploadMediaFromReaderWithContext(context.Background(), uploadType, reader)
+}
+
+//UploadMediaFromReaderWithContext is UploadMediaFromReader bound to ctx
+func (a *uploads) UploadMediaFromReaderWithContext(ctx context.Context, uploadType schemes.UploadType, reader io.Reader) (*schemes.UploadedInfo, error) {
 	result := new(schemes.UploadedInfo)
-	return result, a.uploadMediaFromReader(uploadType, reader, result)
+	return result, a.uploadMediaFromReader(ctx, uploadType, reader, result)
 }
 
 //UploadPhotoFromFile uploads photos to TamTam server
 func (a *uploads) UploadPhotoFromFile(filename string) (*schemes.PhotoTokens, error) {
+	return a.UploadPhotoFromFileWithContext(context.Background(), filename)
+}
+
+//UploadPhotoFromFileWithContext is UploadPhotoFromFile bound to ctx
+func (a *uploads) UploadPhotoFromFileWithContext(ctx context.Context, filename string) (*schemes.PhotoTokens, error) {
 	fh, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer fh.Close()
 	result := new(schemes.PhotoTokens)
-	return result, a.uploadMediaFromReader(schemes.PHOTO, fh, result)
+	return result, a.uploadMediaFromReader(ctx, schemes.PHOTO, fh, result)
 }
 
 //UploadPhotoFromUrl uploads photo from remote server to TamTam server
 func (a *uploads) UploadPhotoFromUrl(u url.URL) (*schemes.PhotoTokens, error) {
-	respFile, err := http.Get(u.String())
+	return a.UploadPhotoFromUrlWithContext(context.Background(), u)
+}
+
+//UploadPhotoFromUrlWithContext is UploadPhotoFromUrl bound to ctx
+func (a *uploads) UploadPhotoFromUrlWithContext(ctx context.Context, u url.URL) (*schemes.PhotoTokens, error) {
+	respFile, err := a.download(ctx, u)
 	if err != nil {
 		return nil, err
 	}
 	defer respFile.Body.Close()
 	result := new(schemes.PhotoTokens)
-	return result, a.uploadMediaFromReader(schemes.PHOTO, respFile.Body, result)
+	return result, a.uploadMediaFromReader(ctx, schemes.PHOTO, respFile.Body, result)
 }
 
 //UploadPhotoFromReader uploads photo from reader
 func (a *uploads) UploadPhotoFromReader(reader io.Reader) (*schemes.PhotoTokens, error) {
+	return a.UploadPhotoFromReaderWithContext(context.Background(), reader)
+}
+
+//UploadPhotoFromReaderWithContext is UploadPhotoFromReader bound to ctx
+func (a *uploads) UploadPhotoFromReaderWithContext(ctx context.Context, reader io.Reader) (*schemes.PhotoTokens, error) {
 	result := new(schemes.PhotoTokens)
-	return result, a.uploadMediaFromReader(schemes.PHOTO, reader, result)
+	return result, a.uploadMediaFromReader(ctx, schemes.PHOTO, reader, result)
 }
 
-func (a *uploads) getUploadURL(uploadType schemes.UploadType) (*schemes.UploadEndpoint, error) {
+func (a *uploads) download(ctx context.Context, u url.URL) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	a.client.setHeaders(req)
+	return a.client.transferClient().Do(req.WithContext(ctx))
+}
+
+func (a *uploads) getUploadURL(ctx context.Context, uploadType schemes.UploadType) (*schemes.UploadEndpoint, error) {
 	result := new(schemes.UploadEndpoint)
 	values := url.Values{}
 	values.Set("type", string(uploadType))
-	body, err := a.client.request(http.MethodPost, "uploads", values, nil)
-	if err != nil {
-		return result, err
-	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-	return result, json.NewDecoder(body).Decode(result)
+	return result, a.client.call(ctx, "Uploads.GetUploadURL", http.MethodPost, "uploads", values, nil, result)
 }
 
-func (a *uploads) uploadMediaFromReader(uploadType schemes.UploadType, reader io.Reader, result interface{}) error {
-	endpoint, err := a.getUploadURL(uploadType)
+func (a *uploads) uploadMediaFromReader(ctx context.Context, uploadType schemes.UploadType, reader io.Reader, result interface{}) error {
+	endpoint, err := a.getUploadURL(ctx, uploadType)
 	if err != nil {
 		return err
 	}
@@ -113,13 +144,19 @@ func (a *uploads) uploadMediaFromReader(uploadType schemes.UploadType, reader io
 	if err := bodyWriter.Close(); err != nil {
 		return err
 	}
-	resp, err := http.Post(endpoint.Url, contentType, bodyBuf)
+	req, err := http.NewRequest(http.MethodPost, endpoint.Url, bodyBuf)
+	if err != nil {
+		return err
+	}
+	a.client.setHeaders(req)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := a.client.transferClient().Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Println(err)
+			a.client.logger.Warn("close response body", "err", err)
 		}
 	}()
 	return json.NewDecoder(resp.Body).Decode(result)
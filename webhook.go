@@ -0,0 +1,243 @@
+package tamtam
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neonxp/tamtam/schemes"
+)
+
+const (
+	defaultWebhookMaxBody  = 1 << 20
+	defaultWebhookQueue    = 100
+	defaultWebhookWait     = 5 * time.Second
+	defaultWebhookSecretQS = "secret"
+)
+
+//WebhookConfig configures webhook server. Zero values mean defaults
+type WebhookConfig struct {
+	Path         string          // Only requests to this path are accepted. Empty means any path
+	Secret       string          // If set, requests must carry it in SecretParam query parameter of URL registered with Subscribe
+	SecretParam  string          // Query parameter with secret. Defaults to "secret"
+	MaxBodySize  int64           // Maximum request body size in bytes. Defaults to 1MB
+	QueueSize    int             // Size of updates channel buffer. Defaults to 100
+	DeliveryWait time.Duration   // How long request waits for free place in queue before answering 503. Defaults to 5s
+	OnError      func(err error) // Called on every update that could not be decoded, such update is still delivered as *schemes.UnknownUpdate, and on every update evicted from full journal
+}
+
+//Webhook is http.Handler receiving updates sent by TamTam to URL registered with Subscriptions.Subscribe
+type Webhook struct {
+	api     *Api
+	cfg     WebhookConfig
+	updates chan schemes.UpdateInterface
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+	active  sync.WaitGroup
+	done    chan struct{}
+}
+
+//NewWebhook returns webhook server. Serve it with any http.Server and read updates from Updates channel
+func (a *Api) NewWebhook(cfg WebhookConfig) *Webhook {
+	if cfg.SecretParam == "" {
+		cfg.SecretParam = defaultWebhookSecretQS
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultWebhookMaxBody
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultWebhookQueue
+	}
+	if cfg.DeliveryWait <= 0 {
+		cfg.DeliveryWait = defaultWebhookWait
+	}
+	return &Webhook{api: a, cfg: cfg, updates: make(chan schemes.UpdateInterface, cfg.QueueSize), closing: make(chan struct{})}
+}
+
+//SubscriptionURL returns URL to register with Subscriptions.Subscribe for webhook served at publicURL host. Path and secret are appended
+func (wh *Webhook) SubscriptionURL(publicURL string) string {
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return publicURL
+	}
+	if wh.cfg.Path != "" {
+		u.Path = strings.TrimRight(u.Path, "/") + wh.cfg.Path
+	}
+	if wh.cfg.Secret != "" {
+		q := u.Query()
+		q.Set(wh.cfg.SecretParam, wh.cfg.Secret)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+//Updates returns channel with received updates. It is closed by Shutdown
+func (wh *Webhook) Updates() <-chan schemes.UpdateInterface {
+	return wh.updates
+}
+
+//ServeHTTP validates request and puts update into queue
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := wh.api.client.logger
+	if wh.cfg.Path != "" && r.URL.Path != wh.cfg.Path {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if wh.cfg.Secret != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get(wh.cfg.SecretParam)), []byte(wh.cfg.Secret)) != 1 {
+		logger.Warn("webhook request with wrong secret", "remote_addr", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || ct != "application/json" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			logger.Warn("close request body", "err", err)
+		}
+	}()
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, wh.cfg.MaxBodySize+1))
+	if err != nil {
+		logger.Warn("read webhook body", "err", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if int64(len(b)) > wh.cfg.MaxBodySize {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(b) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	journal := wh.api.journal
+	var id uint64
+	if journal != nil {
+		ids, dropped, err := journal.append(b)
+		wh.api.journalDropped(dropped, ErrJournalFull, wh.cfg.OnError)
+		if err != nil {
+			logger.Error("journal webhook update", "err", err)
+			wh.unavailable(w)
+			return
+		}
+		id = ids[0]
+	}
+	upd := wh.decode(b)
+	if journal != nil {
+		wh.api.journalTrack(id, upd)
+	}
+	logger.Debug("webhook update received", "update_type", upd.GetUpdateType(), "chat_id", upd.GetChatID(), "user_id", upd.GetUserID())
+	if !wh.enqueue(r.Context(), upd, wh.cfg.DeliveryWait) {
+		logger.Warn("webhook queue is full", "update_type", upd.GetUpdateType())
+		if journal != nil {
+			// Update is not acknowledged and will be sent again
+			if err := journal.discard(id); err != nil {
+				logger.Error("journal webhook update", "err", err)
+			}
+		}
+		wh.unavailable(w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (wh *Webhook) decode(b []byte) schemes.UpdateInterface {
+	upd, err := wh.api.DecodeUpdate(b)
+	if err != nil {
+		wh.api.client.logger.Warn("decode webhook update", "err", err)
+		if wh.cfg.OnError != nil {
+			wh.cfg.OnError(err)
+		}
+	}
+	return upd
+}
+
+func (wh *Webhook) unavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wh.cfg.DeliveryWait/time.Second)+1))
+	http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+}
+
+//replay puts updates left in journal by previous run into queue
+func (wh *Webhook) replay(ctx context.Context, ids []uint64, raws []json.RawMessage) {
+	journal := wh.api.journal
+	for i, raw := range raws {
+		upd := wh.decode(raw)
+		wh.api.journalTrack(ids[i], upd)
+		if !wh.enqueue(ctx, upd, 0) {
+			journal.release(upd)
+			journal.unhand(ids[i+1:]...)
+			return
+		}
+	}
+}
+
+//enqueue puts update into queue waiting for free place at most wait. Zero wait means until ctx is done or Shutdown is called
+func (wh *Webhook) enqueue(ctx context.Context, upd schemes.UpdateInterface, wait time.Duration) bool {
+	wh.mu.RLock()
+	if wh.closed {
+		wh.mu.RUnlock()
+		return false
+	}
+	wh.active.Add(1)
+	wh.mu.RUnlock()
+	defer wh.active.Done()
+	var timeout <-chan time.Time
+	var closing <-chan struct{}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	} else {
+		// Without timeout Shutdown would wait for consumer forever
+		closing = wh.closing
+	}
+	select {
+	case wh.updates <- upd:
+		return true
+	case <-timeout:
+		return false
+	case <-closing:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+//Shutdown stops accepting updates, waits for requests being enqueued and closes Updates channel. Updates already in queue stay readable.
+//If ctx is done earlier, Shutdown returns ctx error, but channel is still closed as soon as the last request is enqueued
+func (wh *Webhook) Shutdown(ctx context.Context) error {
+	wh.mu.Lock()
+	if !wh.closed {
+		wh.closed = true
+		close(wh.closing)
+		wh.done = make(chan struct{})
+		go func() {
+			wh.active.Wait()
+			close(wh.updates)
+			close(wh.done)
+		}()
+	}
+	wh.mu.Unlock()
+	select {
+	case <-wh.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}